@@ -5,21 +5,29 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/goeoeo/gitx/repo"
+	"github.com/goeoeo/gitx/util"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var BranchDelCmd = &cobra.Command{
-	Use:   "branchDel",
+	Use:   "branchDel [规则...]",
 	Short: "删除本地和远程分支",
+	Long: `删除本地和远程分支，规则为普通字符串时模糊匹配，含 * ? 时为通配符，re: 开头为正则
+受保护的分支、当前分支、存在未合并MR的分支不会删除，未配置代码托管平台时只删除本地分支，可使用 --skip-mr-check 同时删除远程分支`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) < 1 {
-			logrus.Errorf("请提供分支关键词")
+		if len(args) < 1 && branchDelMergedInto == "" && branchDelOlderThan <= 0 && !branchDelMine {
+			logrus.Errorf("请提供分支规则或筛选条件")
+			return
+		}
+		//只有时间、作者、合并条件时会匹配到所有远程分支
+		if branchDelRemote && len(args) < 1 {
+			logrus.Errorf("使用 --remote 时请提供分支规则")
 			return
 		}
-		keyword := args[0]
 
 		// 获取当前目录
 		currentDir, err := os.Getwd()
@@ -28,69 +36,113 @@ var BranchDelCmd = &cobra.Command{
 			return
 		}
 
-		// 创建 GitRepo 对象
-		gitRepo := repo.NewGitRepo(currentDir, "")
-
-		// 获取所有本地分支
-		localBranches, err := gitRepo.GetBranchs()
+		// 创建 GitRepo 对象，通过origin地址找到代码托管平台，用于检查MR和受保护的分支
+		url, err := repo.OriginUrl(currentDir)
 		if err != nil {
-			logrus.Errorf("获取本地分支失败: %v", err)
-			return
+			logrus.Warnf("%v，无法检查MR", err)
 		}
+		gitRepo := repo.NewGitRepo(currentDir, url)
 
-		// 模糊匹配本地分支
-		var matchedBranches []string
-		for _, branch := range localBranches {
-			if strings.Contains(branch, keyword) {
-				matchedBranches = append(matchedBranches, branch)
-			}
+		filter := &repo.BranchFilter{
+			Patterns:   args,
+			Remote:     branchDelRemote,
+			MergedInto: branchDelMergedInto,
+			OlderThan:  time.Duration(branchDelOlderThan) * 24 * time.Hour,
+			Mine:       branchDelMine,
+		}
+		candidates, err := gitRepo.FindBranchs(filter, time.Now())
+		if err != nil {
+			logrus.Errorf("查找分支失败: %v", err)
+			return
 		}
 
-		if len(matchedBranches) == 0 {
+		if len(candidates) == 0 {
 			logrus.Infof("没有匹配到分支")
 			return
 		}
 
 		// 列出匹配的分支
-		fmt.Println("匹配到的本地分支:")
-		for _, branch := range matchedBranches {
-			fmt.Printf("- %s\n", branch)
+		fmt.Println("匹配到的分支:")
+		for i, c := range candidates {
+			fmt.Printf("%3d) %s [%s] %s %s\n", i+1, c.Name, c.Where(), c.CommitTime.Format("2006-01-02"), c.Author)
 		}
 
-		// 交互式确认
-		reader := bufio.NewReader(os.Stdin)
-		fmt.Print("确认删除以上所有分支吗？(y/n): ")
-		confirm, _ := reader.ReadString('\n')
-		confirm = strings.TrimSpace(confirm)
-
-		if strings.ToLower(confirm) != "y" {
-			logrus.Infof("取消删除操作")
-			return
+		if !branchDelDryRun && !assumeYes {
+			if candidates = selectBranchs(candidates); len(candidates) == 0 {
+				logrus.Infof("取消删除操作")
+				return
+			}
 		}
 
-		// 删除分支
-		for _, branch := range matchedBranches {
-			// 删除本地分支
-			fmt.Printf("正在删除本地分支: %s\n", branch)
-			err := gitRepo.DelLocalBranch(branch)
-			if err != nil {
-				logrus.Warnf("删除本地分支 %s 失败: %v", branch, err)
-			} else {
-				logrus.Infof("删除本地分支 %s 成功", branch)
-			}
+		var (
+			rows   [][]string
+			failed bool
+		)
+		for _, c := range candidates {
+			gitRepo.DeleteBranch(c, branchDelDryRun, branchDelSkipMrCheck)
+			failed = failed || c.Result == repo.BranchDelFailed
+			rows = append(rows, []string{c.Name, c.Where(), branchDelDesc(c.Result, branchDelDryRun), c.Reason})
+		}
+		util.PrintTable(rows, []string{"分支", "位置", "结果", "原因"})
 
-			// 删除远程分支
-			fmt.Printf("正在删除远程分支: %s\n", branch)
-			err = gitRepo.DelRemoteBranch(branch)
-			if err != nil {
-				logrus.Warnf("删除远程分支 %s 失败: %v", branch, err)
-			} else {
-				logrus.Infof("删除远程分支 %s 成功", branch)
-			}
+		if failed {
+			os.Exit(1)
 		}
 	},
 }
 
+// selectBranchs 交互式选择要删除的分支，y 全部，n 取消，或输入序号如 1,3-5
+func selectBranchs(candidates []*repo.BranchCandidate) []*repo.BranchCandidate {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Print("确认删除以上分支吗？(y 全部/n 取消/序号如 1,3-5): ")
+		input, err := reader.ReadString('\n')
+		input = strings.TrimSpace(input)
+		if err != nil && input == "" {
+			return nil
+		}
+
+		switch strings.ToLower(input) {
+		case "y":
+			return candidates
+		case "n", "":
+			return nil
+		}
+
+		idx, err := repo.ParseSelection(input, len(candidates))
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		selected := make([]*repo.BranchCandidate, 0, len(idx))
+		for _, i := range idx {
+			selected = append(selected, candidates[i])
+		}
+		return selected
+	}
+}
+
+func branchDelDesc(result string, dryRun bool) string {
+	switch result {
+	case repo.BranchDelDeleted:
+		if dryRun {
+			return "将删除"
+		}
+		return "已删除"
+	case repo.BranchDelSkipped:
+		return "跳过"
+	default:
+		return "失败"
+	}
+}
+
 func init() {
-	// 可以添加命令行参数
-}
\ No newline at end of file
+	BranchDelCmd.Flags().BoolVarP(&branchDelRemote, "remote", "r", false, "同时匹配只存在于远程的分支")
+	BranchDelCmd.Flags().StringVar(&branchDelMergedInto, "merged", "", "只删除已合入该分支的分支，如 master")
+	BranchDelCmd.Flags().IntVar(&branchDelOlderThan, "older-than", 0, "只删除最后一次commit早于N天的分支")
+	BranchDelCmd.Flags().BoolVar(&branchDelMine, "mine", false, "只删除最后一次commit作者是自己的分支")
+	BranchDelCmd.Flags().BoolVar(&branchDelDryRun, "dry-run", false, "只打印将要删除的分支")
+	BranchDelCmd.Flags().BoolVar(&branchDelSkipMrCheck, "skip-mr-check", false, "未配置代码托管平台时不检查MR，直接删除远程分支")
+	BranchDelCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "跳过确认，删除所有匹配的分支")
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/goeoeo/gitx/repo"
+	"github.com/goeoeo/gitx/util"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	BranchesCmd = &cobra.Command{
+		Use:   "branches",
+		Short: "管理所有项目的远程分支",
+	}
+
+	branchesAuditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "列出所有项目的远程分支，标记没有MR或MR已合并、关闭的临时分支",
+		Run: func(cmd *cobra.Command, args []string) {
+			var (
+				err   error
+				names []string
+				items []*repo.AuditItem
+				now   = time.Now()
+			)
+
+			config := repo.GetConfig(configPath)
+			if debug {
+				config.LogLevel = 5
+			}
+			config.Init()
+
+			for name := range config.Repo {
+				if project == "" || name == project {
+					names = append(names, name)
+				}
+			}
+			if len(names) == 0 {
+				config.CheckErr(fmt.Errorf("项目仓库信息缺失:%s", project))
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				var (
+					a   *repo.Auditor
+					res []*repo.AuditItem
+				)
+
+				r := config.Repo[name]
+				if r.Path == "" {
+					logrus.Warnf("项目 %s 仓库信息缺失，跳过", name)
+					continue
+				}
+
+				a, err = repo.NewAuditor(name, r, config)
+				config.CheckErr(err)
+				if res, err = a.Audit(now); err != nil {
+					logrus.Warnf("检查项目 %s 失败: %v", name, err)
+					continue
+				}
+
+				for _, it := range res {
+					if !auditStaleOnly || it.Stale {
+						items = append(items, it)
+					}
+				}
+			}
+
+			var rows [][]string
+			for _, it := range items {
+				rows = append(rows, it.Row())
+			}
+
+			switch output {
+			case "json":
+				util.PrintJson(items)
+			case "csv":
+				util.PrintCsv(rows, repo.AuditHeader)
+			case "table", "":
+				util.PrintTable(rows, repo.AuditHeader)
+			default:
+				logrus.Errorf("不支持的输出格式:%s", output)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	BranchesCmd.PersistentFlags().StringVarP(&configPath, "config", "c", defaultConfigPath(), "配置文件路径")
+	BranchesCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "开启debug日志")
+	branchesAuditCmd.Flags().StringVarP(&project, "project", "p", "", "项目，默认所有项目")
+	branchesAuditCmd.Flags().StringVarP(&output, "output", "o", "table", "输出格式:table,csv,json")
+	branchesAuditCmd.Flags().BoolVar(&auditStaleOnly, "stale", false, "只列出待清理的临时分支")
+	BranchesCmd.AddCommand(branchesAuditCmd)
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"github.com/goeoeo/gitx/repo"
+	"github.com/goeoeo/gitx/util"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+var CheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "预测commit cherry-pick到各目标分支是否冲突",
+	Run: func(cmd *cobra.Command, args []string) {
+		var (
+			err      error
+			r        *repo.Repo
+			rc       *repo.RepoCheck
+			checks   []*repo.RepoCheck
+			conflict bool
+		)
+
+		config := repo.GetConfig(configPath)
+		if debug {
+			config.LogLevel = 5
+		}
+
+		config.Init().ParseJIRA(jiraID)
+
+		if branchList != "" {
+			config.Patch.TgtBranchs = strings.Split(branchList, ",")
+		}
+
+		//未指定目标分支时从Jira任务推导
+		if sources := config.Patch.ResolveIssueBranchs(); len(sources) > 0 {
+			repo.PrintBranchSources(config.Patch.JiraId, sources, config.Patch.BranchAlias)
+		}
+
+		if len(config.Patch.TgtBranchs) == 0 {
+			logrus.Fatalf("目标分支不能为空")
+		}
+
+		if project == "" {
+			project = config.Patch.CurrentProject
+		}
+
+		for _, p := range strings.Split(project, ",") {
+			if p == "" {
+				continue
+			}
+
+			r, err = config.LocalRepo(p)
+			config.CheckErr(err)
+
+			rc, err = repo.NewRepoPatch(r, config).IgnoreLocalCommit(force).Check()
+			config.CheckErr(err)
+			checks = append(checks, rc)
+			conflict = conflict || rc.HasConflict()
+		}
+
+		if output == "json" {
+			util.PrintJson(checks)
+		} else {
+			for _, v := range checks {
+				v.Print()
+			}
+		}
+
+		if conflict {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	CheckCmd.Flags().StringVarP(&configPath, "config", "c", defaultConfigPath(), "配置文件路径")
+	CheckCmd.Flags().StringVarP(&project, "project", "p", "", "项目")
+	CheckCmd.Flags().StringVarP(&jiraID, "jiraId", "j", "", "jiraID")
+	CheckCmd.Flags().StringVarP(&branchList, "branchList", "b", "", "目标分支，支持逗号分隔")
+	CheckCmd.Flags().BoolVarP(&force, "force", "f", false, "忽略本地记录，检查所有commit")
+	CheckCmd.Flags().BoolVarP(&debug, "debug", "d", false, "开启debug日志")
+	CheckCmd.Flags().StringVarP(&output, "output", "o", "table", "输出格式:table,json")
+}
@@ -41,9 +41,13 @@ var JiraCmd = &cobra.Command{
 		case "del":
 			err = jc.Del(project, jiraID)
 		case "clear":
-			err = jc.Clear()
+			err = jc.Clear(clearDryRun)
 		case "print":
 			err = jc.Print(project, jiraID)
+		case "import":
+			err = jc.Import(project)
+		case "reconcile":
+			err = jc.Reconcile(project, jiraID, reconcileApply)
 		default:
 			err = fmt.Errorf("action not suppert:%s", actualAction)
 		}
@@ -56,11 +60,12 @@ var JiraCmd = &cobra.Command{
 
 func init() {
 	JiraCmd.Flags().StringVarP(&configPath, "config", "c", defaultConfigPath(), "配置文件路径")
-	JiraCmd.Flags().StringVarP(&action, "action", "a", "", "方法:add,del,clear")
+	JiraCmd.Flags().StringVarP(&action, "action", "a", "", "方法:add,del,clear,print,import,reconcile")
 	JiraCmd.Flags().StringVarP(&project, "project", "p", "", "项目")
 	JiraCmd.Flags().StringVarP(&jiraID, "jiraId", "j", "", "jiraID")
 	JiraCmd.Flags().StringVarP(&branchList, "branchList", "b", "", "目标分支，支持逗号分隔")
-	JiraCmd.PersistentFlags().BoolVarP(&disableCheckMerged, "disableCheckMerged", "d", false, "删除临时分支前是否检查已经合并")
+	JiraCmd.Flags().BoolVar(&clearDryRun, "dry-run", false, "clear时只打印将要删除的分支")
+	JiraCmd.Flags().BoolVar(&reconcileApply, "apply", false, "reconcile时修正记录，默认只打印")
 
 }
 
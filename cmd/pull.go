@@ -32,6 +32,7 @@ func init() {
 
 	PullCmd.Flags().StringVarP(&configPath, "config", "c", defaultConfigPath(), "配置文件路径")
 	PullCmd.Flags().StringVarP(&project, "project", "p", "", "项目")
+	PullCmd.Flags().BoolVar(&pullDelete, "delete", false, "先删除本地目标分支，再从远程分支重建")
 }
 
 func pullProject(project string, config *repo.Config) (err error) {
@@ -41,11 +42,16 @@ func pullProject(project string, config *repo.Config) (err error) {
 		return
 	}
 
+	//本地仓库不存在时克隆到工作区
+	if r, err = config.EnsureRepo(project); err != nil {
+		return
+	}
+
 	logrus.Debugf("git pull patch target branch ok: repo: %s \n", r.Path)
 
 	repoPatch := repo.NewRepoPatch(r, config)
 
-	if err = repoPatch.Pull(false); err != nil {
+	if err = repoPatch.Pull(pullDelete); err != nil {
 		logrus.Debugf("git pull patch repo faild: repo: %s, err: %v \n", r.Path, err)
 		return
 	}
@@ -7,6 +7,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"log"
+	"os"
 	"strings"
 )
 
@@ -17,6 +18,7 @@ var (
 		Run: func(cmd *cobra.Command, args []string) {
 			var (
 				err                     error
+				projects                []string
 				mergeUrls, tmpMergeUrls []*repo.RepoPushResult
 			)
 			config := repo.GetConfig(configPath)
@@ -38,10 +40,49 @@ var (
 				config.Patch.PlanTgtBranchList = strings.Split(planTgtBranchList, ",")
 			}
 
+			if assumeYes {
+				config.Patch.AssumeYes = true
+			}
+
+			if skipCheck {
+				config.Patch.SkipCheck = true
+			}
+
+			if onConflict != "" {
+				config.Patch.ConflictPolicy = onConflict
+			}
+
+			if config.Patch.ConflictPolicy != "" && !util.ContainString(repo.ConflictPolicies, config.Patch.ConflictPolicy) {
+				logrus.Fatalf("不支持的冲突处理策略:%s，可选:%s", config.Patch.ConflictPolicy, strings.Join(repo.ConflictPolicies, ","))
+			}
+
+			//未指定项目时 --status、--continue、--abort 处理所有未完成的push
+			switch {
+			case pushStatus:
+				err = printPushSessions(config)
+				config.CheckErr(err)
+				return
+			case pushAbort:
+				err = abortPush(config)
+				config.CheckErr(err)
+				return
+			case pushContinue:
+				mergeUrls, err = continuePush(config)
+				config.CheckErr(err)
+				printPushResults(mergeUrls)
+				exitIfFailed(mergeUrls)
+				return
+			}
+
 			if project == "" {
 				project = config.Patch.CurrentProject
 			}
 
+			//未指定目标分支时从Jira任务推导
+			if sources := config.Patch.ResolveIssueBranchs(); len(sources) > 0 {
+				repo.PrintBranchSources(config.Patch.JiraId, sources, config.Patch.BranchAlias)
+			}
+
 			if len(config.Patch.TgtBranchs) == 0 {
 				logrus.Fatalf("目标分支不能为空")
 			}
@@ -50,38 +91,33 @@ var (
 				logrus.Fatalf("开发分支不能为空")
 			}
 
-			if project == "" {
-				logrus.Fatalf("项目不能为空")
-			}
+			//推送前检查所有项目，避免只推送了部分项目
+			projects, err = pushProjects(config)
+			config.CheckErr(err)
 
-			for _, project := range strings.Split(project, ",") {
-				if project == "" {
-					continue
-				}
-				tmpMergeUrls, err = pushProject(project, config)
+			if pushDryRun {
+				err = planPush(config, projects)
 				config.CheckErr(err)
-
-				mergeUrls = append(mergeUrls, tmpMergeUrls...)
+				return
 			}
 
-			if len(mergeUrls) == 0 {
+			if pushJobs > 1 {
+				mergeUrls, err = pushParallel(config, projects)
+				printPushResults(mergeUrls)
+				config.CheckErr(err)
+				exitIfFailed(mergeUrls)
 				return
 			}
 
-			logrus.Debugf("patch push ok! \n\n")
+			for _, project := range projects {
+				tmpMergeUrls, err = pushProject(project, config)
+				config.CheckErr(err)
 
-			fmt.Println("result:")
-			var rows [][]string
-			for _, row := range mergeUrls {
-				desc := ""
-				if len(row.OutCommits) > 0 {
-					desc = row.OutCommits[0].Desc
-				}
-				desc = strings.Replace(desc, " ", "", -1)
-				rows = append(rows, []string{row.Project, fmt.Sprintf("%s=>%s", row.DevBranch, row.TargetBranch), desc, row.MergeUrl, row.MergeRes})
+				mergeUrls = append(mergeUrls, tmpMergeUrls...)
 			}
-			//汇总打印
-			util.PrintTable(rows, []string{"项目", "分支", "描述", "MR", "已合入"})
+
+			printPushResults(mergeUrls)
+			exitIfFailed(mergeUrls)
 		},
 	}
 )
@@ -96,17 +132,230 @@ func init() {
 	PushCmd.PersistentFlags().BoolVarP(&force, "force", "f", false, "忽略本地记录，cherry-pick所有commit")
 	PushCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "开启debug日志")
 	PushCmd.PersistentFlags().BoolVarP(&autoMergeMr, "autoMergeMr", "m", false, "自动合并mr")
+	PushCmd.Flags().BoolVar(&pushContinue, "continue", false, "处理完冲突后继续未完成的push")
+	PushCmd.Flags().BoolVar(&pushAbort, "abort", false, "放弃未完成的push")
+	PushCmd.Flags().BoolVar(&pushStatus, "status", false, "查看未完成的push")
+	PushCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "跳过commit确认，用于脚本、定时任务中执行")
+	PushCmd.Flags().IntVar(&pushJobs, "jobs", 1, "并发执行的 项目/目标分支 数量")
+	PushCmd.Flags().BoolVar(&skipCheck, "skip-check", false, "push前不预测cherry-pick冲突")
+	PushCmd.Flags().BoolVar(&pushDryRun, "dry-run", false, "只计算并打印执行计划，不切换分支、不推送、不创建mr")
+	PushCmd.Flags().StringVarP(&output, "output", "o", "table", "执行计划的输出格式:table,json")
+	PushCmd.Flags().StringVar(&onConflict, "on-conflict", "", "cherry-pick冲突的处理策略:abort,skip,pause,fail-branch，默认交互式处理")
+}
+
+// exitIfFailed 存在失败的目标分支时以非0退出
+func exitIfFailed(mergeUrls []*repo.RepoPushResult) {
+	for _, v := range mergeUrls {
+		if v.Failed {
+			os.Exit(1)
+		}
+	}
+}
+
+func printPushResults(mergeUrls []*repo.RepoPushResult) {
+	if len(mergeUrls) == 0 {
+		return
+	}
+
+	logrus.Debugf("patch push ok! \n\n")
+
+	fmt.Println("result:")
+	var rows [][]string
+	for _, row := range mergeUrls {
+		desc := ""
+		if len(row.OutCommits) > 0 {
+			desc = row.OutCommits[0].Desc
+		}
+		desc = strings.Replace(desc, " ", "", -1)
+		rows = append(rows, []string{row.Project, fmt.Sprintf("%s=>%s", row.DevBranch, row.TargetBranch), desc, row.MergeUrl, row.MergeRes, row.Status()})
+	}
+	//汇总打印
+	util.PrintTable(rows, []string{"项目", "分支", "描述", "MR", "已合入", "状态"})
+}
+
+// pushProjects -p 指定的项目，有找不到仓库信息的项目时返回错误
+func pushProjects(config *repo.Config) (projects []string, err error) {
+	for _, p := range strings.Split(project, ",") {
+		if p != "" {
+			projects = append(projects, p)
+		}
+	}
+	if len(projects) == 0 {
+		return nil, fmt.Errorf("项目不能为空")
+	}
+	return config.WorkspaceProjects(projects)
+}
+
+// sessionProjects 需要处理push进度的项目，未指定项目时处理所有未完成的push
+func sessionProjects(config *repo.Config) (projects []string, err error) {
+	var (
+		sessions []*repo.PushSession
+	)
+
+	if project != "" {
+		return pushProjects(config)
+	}
+
+	if sessions, err = repo.ListPushSessions(config); err != nil {
+		return
+	}
+	for _, s := range sessions {
+		projects = append(projects, s.Project)
+	}
+
+	if len(projects) == 0 {
+		err = repo.ErrNoSession
+	}
+	return
+}
+
+func printPushSessions(config *repo.Config) (err error) {
+	var (
+		sessions []*repo.PushSession
+		rows     [][]string
+	)
+
+	if sessions, err = repo.ListPushSessions(config); err != nil {
+		return
+	}
+
+	if len(sessions) == 0 {
+		fmt.Println(repo.ErrNoSession)
+		return
+	}
+
+	for _, s := range sessions {
+		rows = append(rows, s.Rows()...)
+	}
+	util.PrintTable(rows, []string{"项目", "JiraID", "分支", "状态", "进度", "工作目录", "更新时间"})
+	return
+}
+
+func continuePush(config *repo.Config) (mergeUrls []*repo.RepoPushResult, err error) {
+	var (
+		projects []string
+		results  []*repo.RepoPushResult
+	)
+
+	if projects, err = sessionProjects(config); err != nil {
+		return
+	}
+
+	for _, p := range projects {
+		r, ok := config.Repo[p]
+		if !ok {
+			return nil, fmt.Errorf("找不到项目仓库信息:%s", p)
+		}
+
+		if results, err = repo.NewRepoPatch(r, config).Continue(); err != nil {
+			return
+		}
+		mergeUrls = append(mergeUrls, results...)
+	}
+	return
+}
+
+func abortPush(config *repo.Config) (err error) {
+	var (
+		projects []string
+	)
+
+	if projects, err = sessionProjects(config); err != nil {
+		return
+	}
+
+	for _, p := range projects {
+		r, ok := config.Repo[p]
+		if !ok {
+			return fmt.Errorf("找不到项目仓库信息:%s", p)
+		}
+
+		if err = repo.NewRepoPatch(r, config).Abort(); err != nil {
+			return
+		}
+		fmt.Printf("已放弃项目 %s 未完成的push\n", p)
+	}
+	return
+}
+
+// planPush 打印push的执行计划
+func planPush(config *repo.Config, projects []string) (err error) {
+	var (
+		r     *repo.Repo
+		plan  *repo.PushPlan
+		plans []*repo.PushPlan
+	)
+
+	for _, p := range projects {
+		if r, err = config.LocalRepo(p); err != nil {
+			return
+		}
+
+		if autoMergeMr {
+			r.AutoMergeBranchList = config.Patch.GetTgtBranchs()
+		}
+
+		if plan, err = repo.NewRepoPatch(r, config).IgnoreLocalCommit(force).Plan(); err != nil {
+			return
+		}
+		plans = append(plans, plan)
+	}
+
+	switch output {
+	case "json":
+		util.PrintJson(plans)
+	case "table", "":
+		var rows, commitRows [][]string
+		for _, v := range plans {
+			rows = append(rows, v.Rows()...)
+			commitRows = append(commitRows, v.CommitRows()...)
+		}
+		util.PrintTable(rows, []string{"项目", "JiraID", "分支", "临时分支", "待cherry-pick", "已包含", "创建MR", "自动合并", "备注"})
+		util.PrintTable(commitRows, []string{"项目", "目标分支", "动作", "commit", "描述", "时间", "目标commit"})
+	default:
+		return fmt.Errorf("不支持的输出格式:%s", output)
+	}
+	return
+}
+
+// pushParallel 并发推送多个项目、多个目标分支
+func pushParallel(config *repo.Config, projects []string) (mergeUrls []*repo.RepoPushResult, err error) {
+	var (
+		r         *repo.Repo
+		patches   []*repo.RepoPatch
+		scheduler *repo.Scheduler
+	)
+
+	for _, p := range projects {
+		//本地仓库不存在时克隆到工作区
+		if r, err = config.EnsureRepo(p); err != nil {
+			return
+		}
+
+		if autoMergeMr {
+			r.AutoMergeBranchList = config.Patch.GetTgtBranchs()
+		}
+		patches = append(patches, repo.NewRepoPatch(r, config).IgnoreLocalCommit(force))
+	}
+
+	if scheduler, err = repo.NewScheduler(pushJobs); err != nil {
+		return
+	}
+	return scheduler.Push(patches)
 }
 
 func pushProject(project string, config *repo.Config) (mergeUrls []*repo.RepoPushResult, err error) {
-	r, ok := config.Repo[project]
-	if !ok {
-		logrus.Debugf("找不到项目仓库信息:%s\n", project)
+	var (
+		r *repo.Repo
+	)
+
+	//本地仓库不存在时克隆到工作区
+	if r, err = config.EnsureRepo(project); err != nil {
 		return
 	}
 
 	if autoMergeMr {
-		r.AutoMergeBranchList = strings.Split(branchList, ",")
+		r.AutoMergeBranchList = config.Patch.GetTgtBranchs()
 	}
 
 	repoPatch := repo.NewRepoPatch(r, config).IgnoreLocalCommit(force)
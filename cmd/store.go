@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/goeoeo/gitx/repo"
+	"github.com/goeoeo/gitx/util"
+	"github.com/spf13/cobra"
+)
+
+var (
+	StoreCmd = &cobra.Command{
+		Use:   "store",
+		Short: "管理 ~/.patch 下的本地存储",
+	}
+
+	storeMigrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "将本地存储升级到最新版本，升级前备份原文件",
+		Run: func(cmd *cobra.Command, args []string) {
+			var (
+				err      error
+				st       *model.MigrationStatus
+				statuses []*model.MigrationStatus
+				pending  bool
+			)
+
+			//不执行Init，避免读取repo.json时自动升级
+			config := repo.GetConfig(configPath)
+
+			if st, err = model.JiraStoreStatus(); err == nil && !storeCheck && len(st.Pending) > 0 {
+				st, err = model.MigrateJiraStore()
+			}
+			config.CheckErr(err)
+			statuses = append(statuses, st)
+
+			repoFile := config.RepoFile()
+			if st, err = repoFile.Status(); err == nil && !storeCheck && len(st.Pending) > 0 {
+				if err = repoFile.Migrate(); err == nil {
+					st, err = repoFile.Status()
+				}
+			}
+			config.CheckErr(err)
+			statuses = append(statuses, st)
+
+			var rows [][]string
+			for _, v := range statuses {
+				var descs []string
+				for _, m := range v.Pending {
+					descs = append(descs, fmt.Sprintf("v%d:%s", m.Version, m.Desc))
+				}
+				pending = pending || len(v.Pending) > 0
+				rows = append(rows, []string{v.Name, v.Path, fmt.Sprintf("%d", v.Version), fmt.Sprintf("%d", v.Latest),
+					util.Default(strings.Join(descs, "\n"), "-")})
+			}
+			util.PrintTable(rows, []string{"存储", "文件", "当前版本", "最新版本", "待执行的升级"})
+
+			if storeCheck && pending {
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	StoreCmd.PersistentFlags().StringVarP(&configPath, "config", "c", defaultConfigPath(), "配置文件路径")
+	storeMigrateCmd.Flags().BoolVar(&storeCheck, "check", false, "只检查是否需要升级，需要升级时以非0退出")
+	StoreCmd.AddCommand(storeMigrateCmd)
+}
@@ -11,5 +11,24 @@ var (
 	debug                bool   //开启debug日志
 	disableAutoMergeHook bool   //自动合并后是否执行hook
 	autoMergeMr          bool   //自动合并Mr
-	disableCheckMerged   bool   //删除临时分支前是否检查已经合并
+	pushContinue         bool   //继续未完成的push
+	pushAbort            bool   //放弃未完成的push
+	pushStatus           bool   //查看未完成的push
+	assumeYes            bool   //跳过确认
+	onConflict           string //cherry-pick冲突的处理策略
+	pushDryRun           bool   //只打印push的执行计划
+	output               string //输出格式
+	skipCheck            bool   //push前不预测冲突
+	pushJobs             int    //并发push的数量
+	storeCheck           bool   //只检查本地存储是否需要升级
+	reconcileApply       bool   //修正与实际状态不一致的jira记录
+	clearDryRun          bool   //只打印将要清理的临时分支
+	branchDelRemote      bool   //同时匹配只存在于远程的分支
+	branchDelMergedInto  string //只删除已合入该分支的分支
+	branchDelOlderThan   int    //只删除最后一次commit早于N天的分支
+	branchDelMine        bool   //只删除自己提交的分支
+	branchDelDryRun      bool   //只打印将要删除的分支
+	branchDelSkipMrCheck bool   //未配置代码托管平台时不检查MR，直接删除远程分支
+	pullDelete           bool   //pull前删除本地目标分支，从远程分支重建
+	auditStaleOnly       bool   //只列出待清理的临时分支
 )
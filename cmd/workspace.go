@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/goeoeo/gitx/repo"
+	"github.com/goeoeo/gitx/util"
+	"github.com/spf13/cobra"
+)
+
+var (
+	WorkspaceCmd = &cobra.Command{
+		Use:   "workspace",
+		Short: "管理配置中所有项目的本地仓库",
+	}
+
+	workspaceCloneCmd = &cobra.Command{
+		Use:   "clone",
+		Short: "按 域名/组/项目 的目录结构克隆本地不存在的项目",
+		Run: func(cmd *cobra.Command, args []string) {
+			config, names := workspaceProjects()
+			printWorkspaceResults(config.CloneRepos(names))
+		},
+	}
+
+	workspaceSyncCmd = &cobra.Command{
+		Use:   "sync",
+		Short: "并发拉取所有项目的远程分支",
+		Run: func(cmd *cobra.Command, args []string) {
+			config, names := workspaceProjects()
+			printWorkspaceResults(config.SyncRepos(names))
+		},
+	}
+
+	workspaceStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "查看所有项目的当前分支、未提交的文件和相对上游分支的领先、落后",
+		Run: func(cmd *cobra.Command, args []string) {
+			var (
+				rows   [][]string
+				failed bool
+			)
+
+			config, names := workspaceProjects()
+			for _, st := range config.ReposStatus(names) {
+				rows = append(rows, st.Row())
+				failed = failed || st.Result == repo.WorkspaceFailed
+			}
+			util.PrintTable(rows, []string{"项目", "当前分支", "未提交", "领先", "落后", "路径", "状态", "错误"})
+
+			if failed {
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+// workspaceProjects 读取配置，返回 -p 指定的项目，未指定时为所有项目
+func workspaceProjects() (config *repo.Config, names []string) {
+	var (
+		err      error
+		projects []string
+	)
+
+	config = repo.GetConfig(configPath)
+	if debug {
+		config.LogLevel = 5
+	}
+	config.Init()
+
+	if project != "" {
+		projects = strings.Split(project, ",")
+	}
+	names, err = config.WorkspaceProjects(projects)
+	config.CheckErr(err)
+	return
+}
+
+func printWorkspaceResults(res []*repo.WorkspaceStatus) {
+	var (
+		rows   [][]string
+		failed bool
+	)
+
+	for _, st := range res {
+		rows = append(rows, []string{st.Project, util.Default(st.Path, "-"), st.Url, st.Result, st.Error})
+		failed = failed || st.Result == repo.WorkspaceFailed
+	}
+	util.PrintTable(rows, []string{"项目", "路径", "地址", "结果", "错误"})
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	WorkspaceCmd.PersistentFlags().StringVarP(&configPath, "config", "c", defaultConfigPath(), "配置文件路径")
+	WorkspaceCmd.PersistentFlags().StringVarP(&project, "project", "p", "", "项目，支持逗号分隔，默认所有项目")
+	WorkspaceCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "开启debug日志")
+	WorkspaceCmd.AddCommand(workspaceCloneCmd, workspaceSyncCmd, workspaceStatusCmd)
+}
@@ -13,15 +13,13 @@ import (
 )
 
 type JiraController struct {
-	config        *repo.Config
-	jm            *model.JiraMgr
-	projectBranch map[string][]string
+	config *repo.Config
+	jm     *model.JiraMgr
 }
 
 func NewJiraController(config *repo.Config) (jc *JiraController, err error) {
 	jc = &JiraController{
-		config:        config,
-		projectBranch: make(map[string][]string),
+		config: config,
 	}
 
 	//载入jira数据
@@ -30,26 +28,79 @@ func NewJiraController(config *repo.Config) (jc *JiraController, err error) {
 	return
 }
 
-// Clear 清理远程分支没有MR且超过一周的分支
+// Clear 按保留策略清理临时分支，dryRun为true时只打印将要删除的分支
 // 配合定时任务，保持本地项目和远程项目的分支简洁性
-func (jc *JiraController) Clear() (err error) {
-	logrus.Infof("开始清理分支，当前jira数据库中的项目数量:%d\n", len(jc.jm.JiraList))
+func (jc *JiraController) Clear(dryRun bool) (err error) {
+	var (
+		rows [][]string
+		now  = time.Now()
+	)
 
-	// 载入jira数据
+	logrus.Infof("开始清理分支，当前jira数据库中的记录数量:%d\n", len(jc.jm.JiraList))
+
+	ranks := jc.branchRanks()
 	for _, j := range jc.jm.JiraList {
-		logrus.Infof("处理项目:%s\n", j.Project)
 		for _, jb := range j.BranchList {
-			if err = jc.delBranch(j, jb); err != nil {
-				logrus.Errorf("删除分支错误:%s\n", err)
+			result, reason := jc.delBranch(j, jb, ranks[jb], now, dryRun)
+			if result == "" {
+				continue
 			}
+			rows = append(rows, []string{j.Project, j.JiraID, jb.BranchName, cleanupDesc(result, dryRun), reason})
 		}
 	}
 
+	util.PrintTable(rows, []string{"项目", "jira", "临时分支", "结果", "原因"})
+	if dryRun {
+		return
+	}
+
 	// 持久化
 	err = jc.jm.Save()
 	return
 }
 
+// branchRanks 分支在同一项目、目标分支中按push时间倒序的位置，用于保留最近的N个分支
+func (jc *JiraController) branchRanks() map[*model.JiraBranch]int {
+	var (
+		groups = map[string][]*model.JiraBranch{}
+		ranks  = map[*model.JiraBranch]int{}
+	)
+
+	for _, j := range jc.jm.JiraList {
+		for _, jb := range j.BranchList {
+			if jb.BranchName == "" {
+				continue
+			}
+			key := j.Project + "\x00" + jb.TargetBranch
+			groups[key] = append(groups[key], jb)
+		}
+	}
+
+	for _, list := range groups {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].UpdateTime.After(list[j].UpdateTime)
+		})
+		for i, jb := range list {
+			ranks[jb] = i
+		}
+	}
+	return ranks
+}
+
+func cleanupDesc(result string, dryRun bool) string {
+	switch result {
+	case model.CleanupDeleted:
+		if dryRun {
+			return "将删除"
+		}
+		return "已删除"
+	case model.CleanupKept:
+		return "保留"
+	default:
+		return "失败"
+	}
+}
+
 // Add 添加Jira
 func (jc *JiraController) Add(project, jiraID string, targetBranch []string) (err error) {
 	repoCfg := jc.config.Repo[project]
@@ -69,27 +120,26 @@ func (jc *JiraController) Add(project, jiraID string, targetBranch []string) (er
 
 // Del 删除Jira
 func (jc *JiraController) Del(project, jiraID string) (err error) {
-	return jc.jm.DelJira(project, jiraID)
-}
-
-func (jc *JiraController) Detach(project, jiraID, branch string) error {
-	return jc.jm.Detach(project, jiraID, branch)
+	if err = jc.jm.DelJira(project, jiraID); err != nil {
+		return
+	}
+	//共享记录中同步删除
+	jc.syncShared(project)
+	return
 }
 
-func (jc *JiraController) delBranch(j *model.Jira, jb *model.JiraBranch) (err error) {
-	var (
-		branchTime time.Time
-		repoPath   string
-		repoUrl    string
-	)
-
-	// 如果已经标记为已合入，则直接跳过
-	if jb.Merged {
-		logrus.Infof("跳过，分支已标记为已合入:%s \n", jb.BranchName)
+func (jc *JiraController) Detach(project, jiraID, branch string) (err error) {
+	if err = jc.jm.Detach(project, jiraID, branch); err != nil {
 		return
 	}
+	jc.syncShared(project)
+	return
+}
 
-	if jb.DevBranch == "" {
+// delBranch 按保留策略删除临时分支，返回清理结果和原因，不需要处理时结果为空
+// 结果记录在 JiraBranch.Cleanup 中，不影响合并状态
+func (jc *JiraController) delBranch(j *model.Jira, jb *model.JiraBranch, rank int, now time.Time, dryRun bool) (result, reason string) {
+	if jb.BranchName == "" || jb.DevBranch == "" || jb.Cleanup == model.CleanupDeleted {
 		return
 	}
 
@@ -97,98 +147,52 @@ func (jc *JiraController) delBranch(j *model.Jira, jb *model.JiraBranch) (err er
 	repoCfg := jc.config.GetProjectRepoUrl(j.Project)
 	if repoCfg == nil {
 		logrus.Debugf("项目仓库信息缺失:%s，跳过", j.Project)
-		return nil
-	}
-	repoPath = repoCfg.Path
-	repoUrl = repoCfg.Url
-
-	// 创建GitRepo实例
-	git := repo.NewGitRepo(repoPath, repoUrl)
-
-	// 检查分支是否超过一周
-	branchTime, err = git.GetBranchCreateTime(jb.BranchName)
-	if err != nil {
-		logrus.Debugf("获取分支时间错误:%s\n", err)
-		// 即使获取时间失败，也标记为已合入，避免重复处理
-		jb.Merged = true
-		return nil
-	}
-
-	// 检查是否超过一周
-	if time.Since(branchTime) <= 7*24*time.Hour {
-		logrus.Infof("跳过，分支未超过一周:%s \n", jb.BranchName)
 		return
 	}
 
-	logrus.Infof("分支没有MR且超过一周，准备删除:%s \n", jb.BranchName)
-
-	// 删除远程分支 - 即使失败也继续，因为分支可能已经不存在
-	if err := git.DelRemoteBranch(jb.BranchName); err != nil {
-		logrus.Debugf("删除远程分支错误:%s\n", err)
-		// 分支不存在也视为删除成功
-		if strings.Contains(err.Error(), "远程引用不存在") || strings.Contains(err.Error(), "remote ref does not exist") {
-			logrus.Infof("远程分支不存在，视为删除成功:%s\n", jb.BranchName)
+	git := repo.NewGitRepo(repoCfg.Path, repoCfg.Url)
+	defer func() {
+		logrus.Infof("%s %s:%s %s\n", cleanupDesc(result, dryRun), j.Project, jb.BranchName, reason)
+		if !dryRun {
+			jb.SetCleanup(result, reason)
 		}
-	}
+	}()
 
-	// 删除本地分支 - 即使失败也继续，因为分支可能已经不存在
-	if err := git.DelLocalBranch(jb.BranchName); err != nil {
-		logrus.Debugf("删除本地分支错误:%s\n", err)
-		// 分支不存在也视为删除成功
-		if strings.Contains(err.Error(), "错误：无法删除") || strings.Contains(err.Error(), "error: Cannot delete") {
-			logrus.Infof("本地分支不存在，视为删除成功:%s\n", jb.BranchName)
+	policy := jc.config.RetentionPolicy(j.Project)
+	mrState := ""
+	if n := len(jb.MergeRequests); policy.RequireMr && n > 0 {
+		mr, err := git.GetMergeRequest(jb.MergeRequests[n-1].MrId)
+		if err != nil {
+			return model.CleanupFailed, fmt.Sprintf("获取MR失败:%v", err)
 		}
+		mrState = mr.State
 	}
 
-	// 标记为已合入，下次跳过
-	jb.Merged = true
-	logrus.Infof("分支已标记为已合入:%s\n", jb.BranchName)
-
-	return
-}
-
-func (jc *JiraController) branchExists(j *model.Jira, jb *model.JiraBranch) (exists bool, err error) {
-	var (
-		branchList []string
-	)
+	if reason = policy.Keep(jb, rank, mrState, now); reason != "" {
+		return model.CleanupKept, reason
+	}
 
-	if jb.BranchName == "" {
-		return false, nil
+	if git.Protected(jb.BranchName) {
+		return model.CleanupKept, "受保护的分支"
 	}
 
-	if _, ok := jc.projectBranch[j.Project]; !ok {
-		repoCfg := jc.config.GetProjectRepoUrl(j.Project)
-		if repoCfg == nil {
-			logrus.Debugf("项目仓库信息缺失:%s，跳过分支检查", j.Project)
-			return false, nil
-		}
+	if dryRun {
+		return model.CleanupDeleted, ""
+	}
 
-		git := repo.NewGitRepo(repoCfg.Path, repoCfg.Url)
-		if branchList, err = git.GetBranchs(); err != nil {
-			logrus.Debugf("获取本地分支错误:%s\n", err)
-			branchList = []string{}
-		}
-		// 获取远程分支
-		var remoteBranchs []string
-		if remoteBranchs, err = git.GetRemoteBranchs(); err != nil {
-			logrus.Debugf("获取远程分支错误:%s\n", err)
-			remoteBranchs = []string{}
-		}
-		// 合并本地和远程分支
-		branchList = append(branchList, remoteBranchs...)
-		jc.projectBranch[j.Project] = branchList
+	// 删除远程分支，分支已不存在视为删除成功
+	if err := git.DelRemoteBranch(jb.BranchName); err != nil && !strings.Contains(err.Error(), "remote ref does not exist") {
+		return model.CleanupFailed, fmt.Sprintf("删除远程分支失败:%v", err)
 	}
 
-	branchList = jc.projectBranch[j.Project]
-	for _, v := range branchList {
-		// 检查分支名是否匹配
-		if v == jb.BranchName {
-			return true, nil
+	// 删除本地分支
+	if exists, _ := git.HasBranch(jb.BranchName); exists {
+		if err := git.DelLocalBranch(jb.BranchName); err != nil {
+			return model.CleanupFailed, fmt.Sprintf("删除本地分支失败:%v", err)
 		}
 	}
 
-	return false, nil
-
+	return model.CleanupDeleted, ""
 }
 
 func (jc *JiraController) CheckBranchMerged(project, jiraId string) (err error) {
@@ -217,77 +221,18 @@ func (jc *JiraController) CheckBranchMerged(project, jiraId string) (err error)
 
 }
 
-// checkBranchMerged 检查分支对应的JIRA是否已合并
+// checkBranchMerged 检查分支对应的JIRA是否已合并，通过MR状态和目标分支的commit判断，不会切换分支
 func (jc *JiraController) checkBranchMerged(j *model.Jira, jb *model.JiraBranch) (merged bool, err error) {
-	var (
-		commits []*model.CommitInfo
-	)
-
 	if jb.BranchName == "" || jb.Merged {
 		return
 	}
 	repoCfg := jc.config.Repo[j.Project]
-	if repoCfg == nil || repoCfg.Url == "" {
+	if repoCfg == nil || repoCfg.Path == "" {
 		return false, fmt.Errorf("项目仓库信息缺失:%s", j.Project)
 	}
-	logrus.Debugf("satrt checkBranchMerged:%s", j.Project)
-	git := repo.NewGitRepo(repoCfg.Path, repoCfg.Url)
-	//check 目标分支
-	if err = git.SwitchBranch(jb.TargetBranch); err != nil {
-		return
-	}
-	defer func() {
-		_ = git.ResetBranch()
-	}()
-
-	//拉取最新代码
-	if err = git.Pull(); err != nil {
-		if err = git.ResetBranch(); err != nil {
-			return
-		}
-
-		if err = git.DelLocalBranch(jb.TargetBranch); err != nil {
-			return
-		}
-
-		if err = git.NewBranchFromRemote(jb.TargetBranch); err != nil {
-			return
-		}
-
-		if err = git.Pull(); err != nil {
-			return
-		}
-
-	}
-
-	if commits, err = git.GetCommitInfo(j.GetCherryPickMsg()); err != nil {
-		return
-	}
-
-	lci := jb.LastCommitInfo()
-	if lci == nil {
-		return
-	}
-
-	logrus.Debugf(">>>>>>%s,lastcommitTime:%s", jb.TargetBranch, lci.CreateTime)
-	//util.PrintJson(commits)
-
-	after := false
-	for _, c := range commits {
-		if c.CreateTime.After(lci.CreateTime) {
-			after = true
-			break
-		}
-	}
-
-	//远程分支的提交信息没有比 jb中最大的时间大，说明远程还未合入
-	if !after {
-		return
-	}
-
-	//已合并
-	return true, nil
+	logrus.Debugf("start checkBranchMerged:%s,%s", j.Project, jb.BranchName)
 
+	return repo.NewGitRepo(repoCfg.Path, repoCfg.Url).IsMerged(j, jb)
 }
 
 // Print 打印出那些为合并完成的Jira
@@ -296,9 +241,11 @@ func (jc *JiraController) Print(project, jiraId string) (err error) {
 		rows [][]string
 	)
 
+	jc.syncShared(project)
 	if err = jc.syncMergeInfo(project, jiraId); err != nil {
 		return fmt.Errorf("同步merge信息错误:%v", err)
 	}
+	jc.syncShared(project)
 
 	for _, jr := range jc.jm.JiraList {
 		// 指定了JiraID时，忽略项目名称匹配
@@ -325,16 +272,7 @@ func (jc *JiraController) Print(project, jiraId string) (err error) {
 		rows = append(rows, []string{jr.GetDesc(), "MR", "状态", "更新时间"})
 
 		for _, jb := range jr.BranchList {
-			status := "待提交"
-			if jb.DevBranch != "" {
-				status = "待合并"
-			}
-
-			if jb.DevBranch != "" && jb.Merged {
-				status = "已合并"
-			}
-
-			rows = append(rows, []string{fmt.Sprintf("%s=>%s", jb.DevBranch, jb.TargetBranch), jb.MR(), status, jb.UpdateTime.Format("2006-01-02 15-04-05")})
+			rows = append(rows, []string{fmt.Sprintf("%s=>%s", jb.DevBranch, jb.TargetBranch), jb.MR(), jb.StatusDesc(), jb.UpdateTime.Format("2006-01-02 15-04-05")})
 		}
 
 		l := ""
@@ -350,6 +288,177 @@ func (jc *JiraController) Print(project, jiraId string) (err error) {
 	return
 }
 
+// Import 从远程分支和MR重建jira记录，project为空时导入所有项目
+func (jc *JiraController) Import(project string) (err error) {
+	var (
+		names    []string
+		rows     [][]string
+		unmapped [][]string
+		imported int
+	)
+
+	for name := range jc.config.Repo {
+		if project == "" || name == project {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("项目仓库信息缺失:%s", project)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var (
+			im    *repo.Importer
+			items []*repo.ImportItem
+		)
+
+		repoCfg := jc.config.Repo[name]
+		if repoCfg.Path == "" {
+			logrus.Warnf("项目 %s 仓库信息缺失，跳过导入", name)
+			continue
+		}
+
+		if im, err = repo.NewImporter(repoCfg, jc.config); err != nil {
+			return
+		}
+		if items, err = im.Scan(); err != nil {
+			return fmt.Errorf("扫描%s失败:%v", name, err)
+		}
+		im.Apply(jc.jm, name, items)
+
+		for _, it := range items {
+			if it.Result == repo.ImportUnmapped {
+				unmapped = append(unmapped, []string{name, it.Branch, it.MR(), it.Reason})
+				continue
+			}
+			if it.Result == repo.ImportOk {
+				imported++
+			}
+
+			status := "待合并"
+			if it.Merged {
+				status = "已合并"
+			}
+			rows = append(rows, []string{name, it.JiraID, fmt.Sprintf("%s=>%s", it.DevBranch, it.TgtBranch), it.Branch, it.MR(), status, it.Status()})
+		}
+	}
+
+	if err = jc.jm.Save(); err != nil {
+		return
+	}
+
+	util.PrintTable(rows, []string{"项目", "jira", "分支", "临时分支", "MR", "状态", "结果"})
+	fmt.Printf("导入%d条记录\n", imported)
+
+	if len(unmapped) > 0 {
+		fmt.Println("以下分支无法导入:")
+		util.PrintTable(unmapped, []string{"项目", "临时分支", "MR", "原因"})
+	}
+	return
+}
+
+// Reconcile 对比jira记录与分支、MR的实际状态，打印建议的修正，apply为true时修正并保存
+func (jc *JiraController) Reconcile(project, jiraId string, apply bool) (err error) {
+	var (
+		reconcilers = map[string]*repo.Reconciler{}
+		drifts      []*repo.Drift
+		rows        [][]string
+	)
+
+	for _, jr := range jc.jm.JiraList {
+		if project != "" && jr.Project != project {
+			continue
+		}
+		if jiraId != "" && jr.JiraID != jiraId {
+			continue
+		}
+
+		rc, ok := reconcilers[jr.Project]
+		if !ok {
+			repoCfg := jc.config.Repo[jr.Project]
+			if repoCfg == nil || repoCfg.Path == "" {
+				logrus.Warnf("项目 %s 仓库信息缺失，跳过", jr.Project)
+			} else if rc, err = repo.NewReconciler(repoCfg); err != nil {
+				return fmt.Errorf("读取%s分支失败:%v", jr.Project, err)
+			}
+			reconcilers[jr.Project] = rc
+		}
+		if rc == nil {
+			continue
+		}
+
+		for _, jb := range jr.BranchList {
+			d, err := rc.Check(jr, jb)
+			if err != nil {
+				logrus.Warnf("检查分支 %s 失败:%v", jb.BranchName, err)
+				continue
+			}
+			if d != nil {
+				drifts = append(drifts, d)
+			}
+		}
+	}
+
+	if len(drifts) == 0 {
+		fmt.Println("记录与实际状态一致")
+		return
+	}
+
+	for _, d := range drifts {
+		rows = append(rows, []string{
+			d.Project,
+			d.JiraID,
+			d.Branch.BranchName,
+			existsDesc(d.Local),
+			existsDesc(d.Remote),
+			d.MrState(),
+			fmt.Sprintf("%s=>%s", d.Branch.StatusDesc(), model.StateDesc(d.State)),
+			d.Reason,
+		})
+	}
+	util.PrintTable(rows, []string{"项目", "jira", "临时分支", "本地", "远程", "MR", "状态", "原因"})
+
+	if !apply {
+		fmt.Println("使用 --apply 修正以上记录")
+		return
+	}
+
+	for _, d := range drifts {
+		d.Apply()
+	}
+	if err = jc.jm.Save(); err != nil {
+		return
+	}
+	fmt.Printf("已修正%d条记录\n", len(drifts))
+	return
+}
+
+func existsDesc(exists bool) string {
+	if exists {
+		return "存在"
+	}
+	return "已删除"
+}
+
+// syncShared 与配置了共享的仓库同步jira记录，团队成员看到相同的状态
+func (jc *JiraController) syncShared(project string) {
+	for name, r := range jc.config.Repo {
+		if !r.ShareTracking || (project != "" && name != project) {
+			continue
+		}
+
+		if r.Path == "" {
+			logrus.Debugf("项目 %s 仓库信息缺失，跳过共享记录同步", name)
+			continue
+		}
+
+		if err := repo.NewGitRepo(r.Path, r.Url).SyncShared(jc.jm, name); err != nil {
+			logrus.Warnf("同步共享的jira记录失败:%s,%v", name, err)
+		}
+	}
+}
+
 // syncMergeInfo 合并同步信息
 func (jc *JiraController) syncMergeInfo(project, jiraId string) (err error) {
 	var (
@@ -372,7 +481,7 @@ func (jc *JiraController) syncMergeInfo(project, jiraId string) (err error) {
 
 			// 检查是否有可用的仓库信息
 			repoCfg := jc.config.Repo[jr.Project]
-			if repoCfg == nil || repoCfg.Url == "" || repoCfg.Path == "" {
+			if repoCfg == nil || repoCfg.Path == "" {
 				logrus.Debugf("项目 %s 仓库信息缺失，跳过分支合并检查", jr.Project)
 				continue // 跳过此分支的合并检查
 			}
@@ -385,9 +494,13 @@ func (jc *JiraController) syncMergeInfo(project, jiraId string) (err error) {
 
 			if merged {
 				saveData = true
-				jb.Merged = true
+				jb.SetMerged()
 			}
 		}
+
+		if jc.config.ResolveIssue(jr) {
+			saveData = true
+		}
 	}
 
 	if !saveData {
@@ -27,7 +27,7 @@ func TestJiraController_DelJira(t *testing.T) {
 
 func TestJiraController_Clear(t *testing.T) {
 	jira := getJiraController(t)
-	err := jira.Clear()
+	err := jira.Clear(false)
 	assert.Nil(t, err)
 }
 
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"github.com/goeoeo/gitx/util"
 	"sort"
 	"strings"
@@ -12,6 +13,21 @@ const (
 	CommitTypeMsg  = "message"
 )
 
+// JiraBranch 的状态
+const (
+	JiraBranchPending = "pending" //待提交
+	JiraBranchPushed  = "pushed"  //待合并
+	JiraBranchMerged  = "merged"  //已合并
+	JiraBranchClosed  = "closed"  //MR已关闭或分支已删除，未合入
+)
+
+// 清理临时分支的结果，与合并状态无关
+const (
+	CleanupDeleted = "deleted" //已删除
+	CleanupKept    = "kept"    //按保留策略保留
+	CleanupFailed  = "failed"  //删除失败，下次清理时重试
+)
+
 type (
 	Jira struct {
 		Project       string
@@ -23,6 +39,9 @@ type (
 		UpdateTime    time.Time
 		BranchList    []*JiraBranch //一个jira和一个分支对应
 		Merged        bool          //当所有分支合入后，标识为true，当重新patch时，更新为false
+		Summary       string        //Jira任务的标题，配置了Jira时获取
+		Status        string        //Jira任务的状态
+		Resolved      bool          //所有分支合入后已按配置流转Jira任务，当重新patch时，更新为false
 	}
 
 	// JiraBranch JiraId 对应的branch分支
@@ -31,18 +50,26 @@ type (
 		DevBranch     string    //分支
 		TargetBranch  string    //目标分支
 		Merged        bool      //是否已合入目标分支
+		State         string    //状态:pending,pushed,merged,closed
 		UpdateTime    time.Time // 更新时间
 		CreateTime    time.Time
 		Commits       []*CommitInfo //相关的commits
+		Selection     []string      //最近一次push最终选定的commitID，按cherry-pick的顺序排列
 		MergeRequests []*MrInfo
 		LinkInfo      *LinkInfoItem
+		Cleanup       string    //清理临时分支的结果:deleted,kept,failed
+		CleanupReason string    //保留或删除失败的原因
+		CleanupTime   time.Time //最近一次清理的时间
 	}
 
 	CommitInfo struct {
-		CommitId     string
-		Desc         string
-		CreateTime   time.Time
-		TargetExists bool //目标中已包含该commit
+		CommitId       string
+		Desc           string
+		CreateTime     time.Time
+		TargetExists   bool   //目标中已包含该commit
+		PatchId        string //git patch-id，rebase后commitId变化但patch-id不变
+		TargetCommitId string //目标分支中patch-id相同的commit
+		Skipped        bool   //cherry-pick冲突时跳过，没有推送到目标分支
 	}
 	MrInfo struct {
 		Title  string
@@ -67,6 +94,7 @@ func (j *Jira) Init() {
 
 		j.BranchList = append(j.BranchList, &JiraBranch{
 			TargetBranch: branch,
+			State:        JiraBranchPending,
 		})
 
 	}
@@ -87,13 +115,21 @@ func (j *Jira) AttachBranch(branch string) *Jira {
 
 func (j *Jira) Append(jb *JiraBranch) *Jira {
 	j.UpdateTime = time.Now()
+	j.Resolved = false
 	oldJb := j.get(jb.TargetBranch)
 	if oldJb != nil {
 		oldJb.BranchName = jb.BranchName
 		oldJb.TargetBranch = jb.TargetBranch
 		oldJb.DevBranch = jb.DevBranch
 		oldJb.UpdateTime = time.Now()
-		oldJb.Merged = false
+		oldJb.Merged = jb.Merged
+		oldJb.State = jb.pushState()
+		oldJb.Selection = jb.Selection
+		oldJb.Cleanup = ""
+		oldJb.CleanupReason = ""
+		if jb.LinkInfo != nil {
+			oldJb.LinkInfo = jb.LinkInfo
+		}
 		oldJb.Commits = append(oldJb.Commits, jb.Commits...)
 		oldJb.MergeRequests = append(oldJb.MergeRequests, jb.MergeRequests...)
 		sort.SliceStable(oldJb.Commits, func(i, j int) bool {
@@ -105,6 +141,7 @@ func (j *Jira) Append(jb *JiraBranch) *Jira {
 
 	jb.CreateTime = time.Now()
 	jb.UpdateTime = time.Now()
+	jb.State = jb.pushState()
 
 	sort.SliceStable(jb.Commits, func(i, j int) bool {
 		return jb.Commits[i].CreateTime.Before(jb.Commits[j].CreateTime)
@@ -123,15 +160,22 @@ func (j *Jira) get(targetBranch string) *JiraBranch {
 	return nil
 }
 
-// BranchContainCommit 检查当前分支是否已经包含commitId
-func (j *Jira) BranchContainCommit(branch, commitId string) bool {
+// BranchContainCommit 检查当前分支是否已经包含commit，commitId或patch-id相同都视为已包含
+func (j *Jira) BranchContainCommit(branch string, commit *CommitInfo) bool {
 	jb := j.get(branch)
 	if jb == nil {
 		return false
 	}
 
 	for _, v := range jb.Commits {
-		if v.CommitId == commitId {
+		//冲突时跳过的commit下次push时重新挑选
+		if v.Skipped {
+			continue
+		}
+		if v.CommitId == commit.CommitId {
+			return true
+		}
+		if v.PatchId != "" && v.PatchId == commit.PatchId {
 			return true
 		}
 	}
@@ -139,6 +183,117 @@ func (j *Jira) BranchContainCommit(branch, commitId string) bool {
 	return false
 }
 
+// Merge 合并其他人共享的同一个jira记录，每个目标分支保留更新时间较新的记录，返回是否有变化
+// base为上次同步时的共享记录，用于识别删除：base中有而一方没有的目标分支视为已被该方detach，
+// 另一方在base之后没有更新时按删除处理，base为nil时只合并不删除
+func (j *Jira) Merge(other, base *Jira) (changed bool) {
+	var (
+		targets []string
+		inBase  = func(target string) bool { return base != nil && util.ContainString(base.TargetBranch, target) }
+	)
+
+	targets = append(targets, j.TargetBranch...)
+	targets = append(targets, other.TargetBranch...)
+	for _, target := range util.Unique(targets) {
+		local, remote := util.ContainString(j.TargetBranch, target), util.ContainString(other.TargetBranch, target)
+		switch {
+		case local && remote:
+		case remote:
+			//本地已detach，对方在此之后没有更新
+			if inBase(target) && !branchUpdated(other.get(target), base.get(target)) {
+				continue
+			}
+			j.TargetBranch = append(j.TargetBranch, target)
+			changed = true
+		case inBase(target):
+			//对方已detach，本地在此之后没有更新
+			if !branchUpdated(j.get(target), base.get(target)) {
+				j.detach(target)
+				changed = true
+			}
+		}
+	}
+
+	for _, ob := range other.BranchList {
+		if !util.ContainString(j.TargetBranch, ob.TargetBranch) {
+			continue
+		}
+
+		jb := j.get(ob.TargetBranch)
+		if jb == nil {
+			j.BranchList = append(j.BranchList, ob)
+			changed = true
+			continue
+		}
+
+		if ob.UpdateTime.After(jb.UpdateTime) {
+			*jb = *ob
+			changed = true
+		} else if ob.CleanupTime.After(jb.CleanupTime) && ob.CleanupTime.After(jb.UpdateTime) {
+			//清理不会更新UpdateTime，单独合并清理结果，重新push之前的清理结果不再生效
+			jb.Cleanup, jb.CleanupReason, jb.CleanupTime = ob.Cleanup, ob.CleanupReason, ob.CleanupTime
+			changed = true
+		}
+	}
+
+	if other.UpdateTime.After(j.UpdateTime) {
+		j.UpdateTime = other.UpdateTime
+		j.Summary = other.Summary
+		j.Status = other.Status
+		j.Resolved = other.Resolved
+		changed = true
+	}
+	return
+}
+
+// branchUpdated 分支记录在base之后是否有更新，没有分支记录时视为没有更新
+func branchUpdated(jb, base *JiraBranch) bool {
+	if jb == nil {
+		return false
+	}
+	return base == nil || jb.UpdateTime.After(base.UpdateTime)
+}
+
+// detach 移除目标分支及其分支记录
+func (j *Jira) detach(target string) {
+	var (
+		targets    []string
+		branchList []*JiraBranch
+	)
+
+	for _, v := range j.TargetBranch {
+		if v != target {
+			targets = append(targets, v)
+		}
+	}
+	for _, v := range j.BranchList {
+		if v.TargetBranch != target {
+			branchList = append(branchList, v)
+		}
+	}
+	j.TargetBranch, j.BranchList = targets, branchList
+}
+
+// Import 导入从远程分支、MR重建的记录，目标分支已有push记录时不覆盖，返回是否导入
+func (j *Jira) Import(jb *JiraBranch) bool {
+	old := j.get(jb.TargetBranch)
+	if old != nil && old.State != JiraBranchPending {
+		return false
+	}
+
+	j.AttachBranch(jb.TargetBranch)
+	if old != nil {
+		*old = *jb
+	} else {
+		j.BranchList = append(j.BranchList, jb)
+	}
+
+	if jb.UpdateTime.After(j.UpdateTime) {
+		j.UpdateTime = jb.UpdateTime
+	}
+	return true
+}
+
 // Complete 判定当前jiraId 是否已完成
 func (j *Jira) Complete() bool {
 	for _, v := range j.BranchList {
@@ -154,6 +309,53 @@ func (j *Jira) Complete() bool {
 	return true
 }
 
+// pushState push后的状态
+func (jb *JiraBranch) pushState() string {
+	if jb.Merged {
+		return JiraBranchMerged
+	}
+	return JiraBranchPushed
+}
+
+// SetMerged 标记为已合入目标分支
+func (jb *JiraBranch) SetMerged() {
+	jb.Merged = true
+	jb.State = JiraBranchMerged
+}
+
+// SetState 修正状态，Merged与状态保持一致
+func (jb *JiraBranch) SetState(state string) {
+	jb.State = state
+	jb.Merged = state == JiraBranchMerged
+	jb.UpdateTime = time.Now()
+}
+
+// SetCleanup 记录清理临时分支的结果
+func (jb *JiraBranch) SetCleanup(result, reason string) {
+	jb.Cleanup = result
+	jb.CleanupReason = reason
+	jb.CleanupTime = time.Now()
+}
+
+// StatusDesc 状态的描述
+func (jb *JiraBranch) StatusDesc() string {
+	return StateDesc(jb.State)
+}
+
+// StateDesc 状态的描述
+func StateDesc(state string) string {
+	switch state {
+	case JiraBranchMerged:
+		return "已合并"
+	case JiraBranchPushed:
+		return "待合并"
+	case JiraBranchClosed:
+		return "已关闭"
+	default:
+		return "待提交"
+	}
+}
+
 func (jb *JiraBranch) Desc(first bool) string {
 	var desc []string
 	for _, v := range jb.Commits {
@@ -198,6 +400,10 @@ func (j *Jira) AddTargetBranch(tgt []string) *Jira {
 }
 
 func (j *Jira) GetDesc() string {
+	if j.Summary != "" {
+		return fmt.Sprintf("%s:%s[%s]", j.JiraID, j.Summary, j.Status)
+	}
+
 	for _, jb := range j.BranchList {
 		desc := strings.Replace(jb.Desc(false), " ", "", -1)
 		if desc != "" {
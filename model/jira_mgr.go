@@ -1,33 +1,55 @@
 package model
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/goeoeo/gitx/util"
+	bolt "go.etcd.io/bbolt"
 )
 
 type JiraMgr struct {
 	saveDir  string
-	jsonPath string
+	jsonPath string //旧版本的存储文件，首次使用时迁移到store
+	store    *Store
 	JiraList []*Jira
+	saved    map[string][]byte //载入或上次保存时的记录，保存时只写入有变化的记录
+	readOnly bool              //只读载入，不能保存
+	mu       sync.Mutex        //并发push时保护JiraList
 }
 
-func NewJiraMgr() (jm *JiraMgr, err error) {
+func newJiraMgr() *JiraMgr {
 	d, _ := os.UserHomeDir()
-	jm = &JiraMgr{
+	return &JiraMgr{
 		saveDir:  filepath.Join(d, ".patch"),
 		jsonPath: filepath.Join(d, ".patch", "jira.json"),
+		store:    NewStore(filepath.Join(d, ".patch", "jira.db")),
 	}
+}
+
+// NewJiraMgr 载入jira记录，存储格式低于最新版本时先备份再升级
+func NewJiraMgr() (jm *JiraMgr, err error) {
+	jm = newJiraMgr()
 
 	// 确保保存目录存在
 	if err = os.MkdirAll(jm.saveDir, 0755); err != nil {
 		return nil, fmt.Errorf("create save directory error: %v", err)
 	}
 
+	if err = jm.migrate(); err != nil {
+		return
+	}
+
+	if _, err = jm.store.Migrate(); err != nil {
+		return
+	}
+
 	if err = jm.load(); err != nil {
 		return
 	}
@@ -38,6 +60,28 @@ func NewJiraMgr() (jm *JiraMgr, err error) {
 	return
 }
 
+// LoadJiraMgr 只读载入jira记录，不会迁移jira.json、升级数据库或创建目录，用于 push --dry-run、check 等不应有副作用的命令
+func LoadJiraMgr() (jm *JiraMgr, err error) {
+	jm = newJiraMgr()
+	jm.readOnly = true
+
+	if !jm.store.Exists() {
+		if jm.JiraList, err = jm.readJson(); err != nil {
+			return
+		}
+	} else if jm.JiraList, err = jm.store.All(); err != nil {
+		return
+	}
+
+	if jm.JiraList == nil {
+		jm.JiraList = []*Jira{}
+	}
+	for _, v := range jm.JiraList {
+		v.Init()
+	}
+	return
+}
+
 func (jm *JiraMgr) AddJira(project, jiraID string, targetBranch []string) (err error) {
 	j := jm.GetOrCreate(project, jiraID, CommitTypeJira, "")
 
@@ -94,7 +138,7 @@ func (jm *JiraMgr) Detach(project, jiraID, branch string) (err error) {
 			}
 
 			v.BranchList = branchList
-
+			v.UpdateTime = time.Now()
 		}
 
 	}
@@ -102,44 +146,268 @@ func (jm *JiraMgr) Detach(project, jiraID, branch string) (err error) {
 }
 
 func (jm *JiraMgr) load() (err error) {
-	// 检查文件是否存在
-	if _, err = os.Stat(jm.jsonPath); os.IsNotExist(err) {
-		// 文件不存在，初始化空列表
+	if jm.JiraList, err = jm.store.All(); err != nil {
+		return
+	}
+
+	if jm.JiraList == nil {
 		jm.JiraList = []*Jira{}
-		return nil
 	}
 
+	jm.saved = make(map[string][]byte)
+	for _, v := range jm.JiraList {
+		if jm.saved[string(jiraKey(v.Project, v.JiraID))], err = json.Marshal(v); err != nil {
+			return fmt.Errorf("marshal jira data error: %v", err)
+		}
+	}
+	return
+}
+
+// migrate 将旧版本的jira.json升级后迁移到store，迁移后重命名为jira.json.bak
+func (jm *JiraMgr) migrate() (err error) {
+	var (
+		list []*Jira
+	)
+
+	if jm.store.Exists() || !util.FileExists(jm.jsonPath) {
+		return
+	}
+
+	if list, err = jm.readJson(); err != nil {
+		return
+	}
+
+	if err = jm.store.Write(list, nil); err != nil {
+		return
+	}
+
+	if err = os.Rename(jm.jsonPath, jm.jsonPath+".bak"); err != nil && !os.IsNotExist(err) {
+		return
+	}
+	return nil
+}
+
+// readJson 读取旧版本的jira.json并在内存中升级到最新版本，文件不存在时返回空
+func (jm *JiraMgr) readJson() (list []*Jira, err error) {
+	var (
+		data    []byte
+		version int
+	)
+
 	b, err := os.ReadFile(jm.jsonPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
-		return fmt.Errorf("read jira.json error: %v", err)
+		return nil, fmt.Errorf("read jira.json error: %v", err)
 	}
-
 	if len(b) == 0 {
-		// 空文件，初始化空列表
-		jm.JiraList = []*Jira{}
-		return nil
+		return
+	}
+
+	data, version = decodeEnvelope(b)
+	if data, err = JiraMigrator.Upgrade(data, version); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("unmarshal jira.json error: %v", err)
+	}
+	return
+}
+
+// JiraStoreStatus jira记录存储的版本信息，不会执行升级，jira.json 还未迁移时返回 jira.json 的版本
+func JiraStoreStatus() (*MigrationStatus, error) {
+	jm := newJiraMgr()
+	if !jm.store.Exists() && util.FileExists(jm.jsonPath) {
+		return (&VersionedFile{Path: jm.jsonPath, Migrator: JiraMigrator}).Status()
 	}
+	return jm.store.Status()
+}
 
-	// 解析 JSON 数据
-	if err = json.Unmarshal(b, &jm.JiraList); err != nil {
-		return fmt.Errorf("unmarshal jira.json error: %v", err)
+// MigrateJiraStore 将jira记录存储升级到最新版本
+func MigrateJiraStore() (*MigrationStatus, error) {
+	jm, err := NewJiraMgr()
+	if err != nil {
+		return nil, err
 	}
+	return jm.store.Status()
+}
+
+// List 项目的所有jira记录
+func (jm *JiraMgr) List(project string) (res []*Jira) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
 
+	for _, v := range jm.JiraList {
+		if v.Project == project {
+			res = append(res, v)
+		}
+	}
 	return
 }
 
+// Merge 将其他人共享的jira记录合并到项目中，返回是否有变化
+// base为上次同步时的共享记录：base中有而远程没有的记录视为已被其他人删除，本地在此之后没有更新时一并删除；
+// base中有而本地没有的记录视为本地已删除，远程在此之后没有更新时不再加回
+func (jm *JiraMgr) Merge(project string, list, base []*Jira) (changed bool) {
+	var (
+		baseMap   = map[string]*Jira{}
+		remoteMap = map[string]bool{}
+		jrl       []*Jira
+	)
+
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	for _, v := range base {
+		baseMap[v.JiraID] = v
+	}
+
+	for _, v := range list {
+		v.Project = project
+		remoteMap[v.JiraID] = true
+
+		b := baseMap[v.JiraID]
+		j := jm.get(project, v.JiraID)
+		if j == nil {
+			if b != nil && !v.UpdateTime.After(b.UpdateTime) {
+				continue
+			}
+			jm.JiraList = append(jm.JiraList, v)
+			changed = true
+			continue
+		}
+
+		if j.Merge(v, b) {
+			changed = true
+		}
+	}
+
+	for _, v := range jm.JiraList {
+		if v.Project == project && !remoteMap[v.JiraID] {
+			if b := baseMap[v.JiraID]; b != nil && !v.UpdateTime.After(b.UpdateTime) {
+				changed = true
+				continue
+			}
+		}
+		jrl = append(jrl, v)
+	}
+	jm.JiraList = jrl
+	return
+}
+
+// FindByBranch 通过临时分支查找jira记录，不存在时返回nil
+func (jm *JiraMgr) FindByBranch(project, branchName string) *Jira {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	for _, v := range jm.JiraList {
+		if v.Project != project {
+			continue
+		}
+		for _, jb := range v.BranchList {
+			if jb.BranchName == branchName {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// WithLock 在锁内读写jira记录，用于并发push
+func (jm *JiraMgr) WithLock(fn func()) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	fn()
+}
+
+// Save 只写入有变化和已删除的记录，不会覆盖其他进程对其他记录的修改
+// 同一条记录在载入后被其他进程修改时，以载入时的记录为基准与其合并后再写入
 func (jm *JiraMgr) Save() (err error) {
-	// 序列化 JiraList 为 JSON
-	b, err := json.MarshalIndent(jm.JiraList, "", "  ")
+	var (
+		puts, dels []*Jira
+		saved      = make(map[string][]byte)
+	)
+
+	if jm.readOnly {
+		return fmt.Errorf("只读载入的jira记录不能保存")
+	}
+
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	for _, v := range jm.JiraList {
+		key := string(jiraKey(v.Project, v.JiraID))
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("marshal jira data error: %v", err)
+		}
+
+		saved[key] = b
+		if !bytes.Equal(jm.saved[key], b) {
+			puts = append(puts, v)
+		}
+	}
+
+	for key := range jm.saved {
+		if _, ok := saved[key]; !ok {
+			p := strings.SplitN(key, "\x00", 2)
+			dels = append(dels, &Jira{Project: p[0], JiraID: p[1]})
+		}
+	}
+
+	if len(puts) == 0 && len(dels) == 0 {
+		return
+	}
+
+	err = jm.store.update(func(tx *bolt.Tx) (err error) {
+		for _, j := range dels {
+			if err = deleteJira(tx, j.Project, j.JiraID); err != nil {
+				return
+			}
+		}
+
+		for _, j := range puts {
+			key := jiraKey(j.Project, j.JiraID)
+			if stored := tx.Bucket(bucketJira).Get(key); stored != nil && !bytes.Equal(stored, jm.saved[string(key)]) {
+				if err = jm.mergeStored(j, stored); err != nil {
+					return
+				}
+				if saved[string(key)], err = json.Marshal(j); err != nil {
+					return fmt.Errorf("marshal jira data error: %v", err)
+				}
+			}
+
+			if err = putJira(tx, j); err != nil {
+				return
+			}
+		}
+		return
+	})
 	if err != nil {
-		return fmt.Errorf("marshal jira data error: %v", err)
+		return fmt.Errorf("write jira.db error: %v", err)
 	}
 
-	// 写入文件
-	if err = os.WriteFile(jm.jsonPath, b, 0644); err != nil {
-		return fmt.Errorf("write jira.json error: %v", err)
+	jm.saved = saved
+	return
+}
+
+// mergeStored 合并其他进程在载入后写入的同一条记录
+func (jm *JiraMgr) mergeStored(j *Jira, stored []byte) (err error) {
+	var (
+		other, base *Jira
+	)
+
+	if other, err = decodeJira(stored); err != nil {
+		return
+	}
+	if b := jm.saved[string(jiraKey(j.Project, j.JiraID))]; b != nil {
+		if base, err = decodeJira(b); err != nil {
+			return
+		}
 	}
 
+	j.Merge(other, base)
 	return
 }
 
@@ -153,6 +421,9 @@ func (jm *JiraMgr) get(project, jiraID string) *Jira {
 }
 
 func (jm *JiraMgr) GetOrCreate(project, jiraID, commitType, commitMsg string) *Jira {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
 	j := jm.get(project, jiraID)
 	if j != nil {
 		return j
@@ -0,0 +1,273 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type (
+	// Migration 存储格式的一次升级，Version 为升级后的版本
+	// Up 操作原始的json，不依赖当前的结构体定义
+	Migration struct {
+		Version int
+		Desc    string
+		Up      func(data []byte) ([]byte, error)
+	}
+
+	// Migrator 按版本顺序升级存储的数据，没有版本信息的旧数据视为版本0
+	Migrator struct {
+		Name       string
+		Migrations []*Migration //按Version递增
+	}
+
+	// MigrationStatus 存储文件的版本信息
+	MigrationStatus struct {
+		Name    string
+		Path    string
+		Version int
+		Latest  int
+		Pending []*Migration
+	}
+
+	// envelope 带版本的json文件格式
+	envelope struct {
+		Version int             `json:"version"`
+		Data    json.RawMessage `json:"data"`
+	}
+
+	// VersionedFile 带版本的json文件，读取时自动升级到最新版本，升级前备份原文件
+	VersionedFile struct {
+		Path     string
+		Migrator *Migrator
+	}
+)
+
+// JiraMigrator jira记录的升级，数据为jira记录的json数组
+var JiraMigrator = &Migrator{
+	Name: "jira",
+	Migrations: []*Migration{
+		{Version: 1, Desc: "JiraBranch 增加 State，由 DevBranch、Merged 推导", Up: migrateJiraBranchState},
+	},
+}
+
+// RepoMigrator repo.json 的升级，数据为 项目=>仓库信息
+var RepoMigrator = &Migrator{
+	Name: "repo",
+	Migrations: []*Migration{
+		{Version: 1, Desc: "增加版本信息", Up: func(data []byte) ([]byte, error) { return data, nil }},
+	},
+}
+
+// Latest 最新的版本
+func (m *Migrator) Latest() int {
+	if len(m.Migrations) == 0 {
+		return 0
+	}
+	return m.Migrations[len(m.Migrations)-1].Version
+}
+
+// Pending 从version升级到最新版本需要执行的升级
+func (m *Migrator) Pending(version int) (res []*Migration) {
+	for _, v := range m.Migrations {
+		if v.Version > version {
+			res = append(res, v)
+		}
+	}
+	return
+}
+
+// Upgrade 将version版本的数据升级到最新版本
+func (m *Migrator) Upgrade(data []byte, version int) (_ []byte, err error) {
+	if version > m.Latest() {
+		return nil, fmt.Errorf("%s 的版本%d高于当前支持的版本%d，请升级gitx", m.Name, version, m.Latest())
+	}
+
+	for _, v := range m.Pending(version) {
+		if data, err = v.Up(data); err != nil {
+			return nil, fmt.Errorf("%s 升级到版本%d失败:%v", m.Name, v.Version, err)
+		}
+	}
+	return data, nil
+}
+
+func (m *Migrator) status(path string, version int) *MigrationStatus {
+	return &MigrationStatus{
+		Name:    m.Name,
+		Path:    path,
+		Version: version,
+		Latest:  m.Latest(),
+		Pending: m.Pending(version),
+	}
+}
+
+// Status 文件的版本信息，文件不存在时视为最新版本
+func (f *VersionedFile) Status() (*MigrationStatus, error) {
+	b, err := os.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return f.Migrator.status(f.Path, f.Migrator.Latest()), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, version := decodeEnvelope(b)
+	return f.Migrator.status(f.Path, version), nil
+}
+
+// Read 读取文件，版本低于最新版本时先备份再升级，文件不存在时不处理
+func (f *VersionedFile) Read(v any) (err error) {
+	var (
+		data []byte
+	)
+
+	if data, err = f.load(); err != nil || data == nil {
+		return
+	}
+
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("unmarshal %s error: %v", f.Path, err)
+	}
+	return
+}
+
+// Migrate 将文件升级到最新版本
+func (f *VersionedFile) Migrate() (err error) {
+	_, err = f.load()
+	return
+}
+
+// Write 以最新版本写入文件，先写临时文件再重命名，避免写入中断时损坏文件
+func (f *VersionedFile) Write(v any) (err error) {
+	var (
+		data []byte
+	)
+
+	if data, err = json.Marshal(v); err != nil {
+		return
+	}
+	return f.write(data)
+}
+
+func (f *VersionedFile) load() (data []byte, err error) {
+	var (
+		b       []byte
+		version int
+	)
+
+	if b, err = os.ReadFile(f.Path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return
+	}
+
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	data, version = decodeEnvelope(b)
+	if version == f.Migrator.Latest() {
+		return
+	}
+
+	if data, err = f.Migrator.Upgrade(data, version); err != nil {
+		return
+	}
+
+	if err = writeFileAtomic(backupPath(f.Path, version), b, 0644); err != nil {
+		return nil, fmt.Errorf("备份%s失败:%v", f.Path, err)
+	}
+
+	return data, f.write(data)
+}
+
+func (f *VersionedFile) write(data []byte) (err error) {
+	var (
+		b []byte
+	)
+
+	if b, err = json.MarshalIndent(&envelope{Version: f.Migrator.Latest(), Data: data}, "", "  "); err != nil {
+		return
+	}
+	return writeFileAtomic(f.Path, b, 0644)
+}
+
+// decodeEnvelope 解析带版本的数据，没有版本信息的旧文件视为版本0
+func decodeEnvelope(b []byte) (data []byte, version int) {
+	var e envelope
+	if err := json.Unmarshal(b, &e); err == nil && e.Version > 0 && len(e.Data) > 0 {
+		return e.Data, e.Version
+	}
+	return b, 0
+}
+
+// backupPath 升级前的备份文件
+func backupPath(path string, version int) string {
+	return fmt.Sprintf("%s.v%d.bak", path, version)
+}
+
+func writeFileAtomic(path string, b []byte, perm os.FileMode) (err error) {
+	var (
+		f *os.File
+	)
+
+	if f, err = os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*"); err != nil {
+		return
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(b); err != nil {
+		_ = f.Close()
+		return
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	if err = os.Chmod(f.Name(), perm); err != nil {
+		return
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// migrateJiraBranchState 旧记录通过 DevBranch 是否为空、Merged 判断状态，升级后记录在 State 中
+func migrateJiraBranchState(data []byte) ([]byte, error) {
+	var (
+		list []map[string]any
+	)
+
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	for _, j := range list {
+		branchList, _ := j["BranchList"].([]any)
+		for _, v := range branchList {
+			jb, ok := v.(map[string]any)
+			if !ok {
+				continue
+			}
+			if state, _ := jb["State"].(string); state != "" {
+				continue
+			}
+
+			devBranch, _ := jb["DevBranch"].(string)
+			merged, _ := jb["Merged"].(bool)
+			switch {
+			case devBranch == "":
+				jb["State"] = JiraBranchPending
+			case merged:
+				jb["State"] = JiraBranchMerged
+			default:
+				jb["State"] = JiraBranchPushed
+			}
+		}
+	}
+
+	return json.Marshal(list)
+}
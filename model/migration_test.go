@@ -0,0 +1,133 @@
+package model
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMigrator(t *testing.T) {
+	m := &Migrator{Name: "test", Migrations: []*Migration{
+		{Version: 1, Up: func(data []byte) ([]byte, error) { return append(data, '1'), nil }},
+		{Version: 2, Up: func(data []byte) ([]byte, error) { return append(data, '2'), nil }},
+	}}
+
+	assert.Equal(t, 2, m.Latest())
+	assert.Len(t, m.Pending(0), 2)
+	assert.Len(t, m.Pending(1), 1)
+
+	data, err := m.Upgrade([]byte("v"), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "v12", string(data))
+
+	data, err = m.Upgrade([]byte("v"), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "v2", string(data))
+
+	_, err = m.Upgrade([]byte("v"), 3)
+	assert.NotNil(t, err)
+}
+
+func TestVersionedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo.json")
+	f := &VersionedFile{Path: path, Migrator: RepoMigrator}
+
+	//文件不存在
+	var m map[string]string
+	assert.Nil(t, f.Read(&m))
+	st, err := f.Status()
+	assert.Nil(t, err)
+	assert.Empty(t, st.Pending)
+
+	//没有版本信息的旧文件，读取时备份并升级
+	assert.Nil(t, os.WriteFile(path, []byte(`{"work":"git@x"}`), 0644))
+	st, err = f.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, st.Version)
+	assert.Len(t, st.Pending, 1)
+
+	assert.Nil(t, f.Read(&m))
+	assert.Equal(t, map[string]string{"work": "git@x"}, m)
+
+	b, err := os.ReadFile(path + ".v0.bak")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"work":"git@x"}`, string(b))
+
+	st, err = f.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, st.Version)
+	assert.Empty(t, st.Pending)
+
+	m["common"] = "git@y"
+	assert.Nil(t, f.Write(m))
+	m = nil
+	assert.Nil(t, f.Read(&m))
+	assert.Len(t, m, 2)
+}
+
+func TestMigrateJiraBranchState(t *testing.T) {
+	data, err := migrateJiraBranchState([]byte(`[{"JiraID":"VM-1","BranchList":[
+		{"TargetBranch":"dev","DevBranch":"f","Merged":true},
+		{"TargetBranch":"qa","DevBranch":"f"},
+		{"TargetBranch":"staging"}]}]`))
+	assert.Nil(t, err)
+
+	var list []*Jira
+	assert.Nil(t, json.Unmarshal(data, &list))
+	assert.Equal(t, JiraBranchMerged, list[0].BranchList[0].State)
+	assert.Equal(t, JiraBranchPushed, list[0].BranchList[1].State)
+	assert.Equal(t, JiraBranchPending, list[0].BranchList[2].State)
+	assert.Equal(t, "待提交", list[0].BranchList[2].StatusDesc())
+}
+
+func TestStore_Migrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jira.db")
+
+	//没有版本信息的数据库
+	db, err := bolt.Open(path, 0644, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket(bucketJira)
+		if err != nil {
+			return err
+		}
+		return b.Put(jiraKey("work", "VM-1"), []byte(`{"Project":"work","JiraID":"VM-1","BranchList":[{"BranchName":"VM-1_x_dev","DevBranch":"f","TargetBranch":"dev"}]}`))
+	}))
+	assert.Nil(t, db.Close())
+
+	s := NewStore(path)
+	st, err := s.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, st.Version)
+
+	//只读时在内存中升级，不写入数据库
+	list, err := s.All()
+	assert.Nil(t, err)
+	assert.Equal(t, JiraBranchPushed, list[0].BranchList[0].State)
+	st, err = s.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, st.Version)
+
+	st, err = s.Migrate()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, st.Version)
+	assert.Empty(t, st.Pending)
+
+	_, err = os.Stat(path + ".v0.bak")
+	assert.Nil(t, err)
+
+	j, err := s.FindByBranch("work", "VM-1_x_dev")
+	assert.Nil(t, err)
+	assert.Equal(t, JiraBranchPushed, j.BranchList[0].State)
+
+	//新建的数据库为最新版本
+	s = NewStore(filepath.Join(t.TempDir(), "jira.db"))
+	assert.Nil(t, s.Write([]*Jira{{Project: "work", JiraID: "VM-1"}}, nil))
+	st, err = s.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, JiraMigrator.Latest(), st.Version)
+}
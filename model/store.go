@@ -0,0 +1,355 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketJira      = []byte("jira")       //project\x00jiraID => Jira
+	bucketJiraIdx   = []byte("jira_idx")   //jiraID\x00project => 空
+	bucketBranchIdx = []byte("branch_idx") //project\x00branchName => project\x00jiraID
+	bucketMeta      = []byte("meta")       //version => 存储格式的版本，见 JiraMigrator
+
+	versionKey = []byte("version")
+)
+
+// Store 基于bbolt的jira记录存储，每次读写时打开数据库，数据库文件锁保证多个进程互斥
+type Store struct {
+	path    string
+	timeout time.Duration
+}
+
+func NewStore(path string) *Store {
+	return &Store{
+		path:    path,
+		timeout: 30 * time.Second,
+	}
+}
+
+func jiraKey(project, jiraID string) []byte {
+	return []byte(project + "\x00" + jiraID)
+}
+
+// Exists 数据库文件是否存在
+func (s *Store) Exists() bool {
+	_, err := os.Stat(s.path)
+	return err == nil
+}
+
+// All 所有的jira记录，按创建时间排序，数据库低于最新版本时只在内存中升级，不会写入数据库
+func (s *Store) All() (res []*Jira, err error) {
+	var (
+		list    []json.RawMessage
+		data    []byte
+		version = JiraMigrator.Latest()
+	)
+
+	if err = s.view(func(tx *bolt.Tx) error {
+		version = storeVersion(tx)
+		return tx.Bucket(bucketJira).ForEach(func(k, v []byte) error {
+			list = append(list, append(json.RawMessage{}, v...))
+			return nil
+		})
+	}); err != nil || len(list) == 0 {
+		return
+	}
+
+	if data, err = json.Marshal(list); err != nil {
+		return
+	}
+	if data, err = JiraMigrator.Upgrade(data, version); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("unmarshal jira error: %v", err)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].CreateTime.Before(res[j].CreateTime)
+	})
+	return
+}
+
+// Get 获取项目的jira记录，不存在时返回nil
+func (s *Store) Get(project, jiraID string) (j *Jira, err error) {
+	err = s.view(func(tx *bolt.Tx) (err error) {
+		j, err = getJira(tx, jiraKey(project, jiraID))
+		return
+	})
+	return
+}
+
+// ListByProject 项目的所有jira记录
+func (s *Store) ListByProject(project string) (res []*Jira, err error) {
+	err = s.view(func(tx *bolt.Tx) error {
+		prefix := []byte(project + "\x00")
+		c := tx.Bucket(bucketJira).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			j, err := decodeJira(v)
+			if err != nil {
+				return err
+			}
+			res = append(res, j)
+		}
+		return nil
+	})
+	return
+}
+
+// ListByJiraID jiraID在各项目中的记录
+func (s *Store) ListByJiraID(jiraID string) (res []*Jira, err error) {
+	err = s.view(func(tx *bolt.Tx) error {
+		prefix := []byte(jiraID + "\x00")
+		c := tx.Bucket(bucketJiraIdx).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			j, err := getJira(tx, jiraKey(string(k[len(prefix):]), jiraID))
+			if err != nil {
+				return err
+			}
+			if j != nil {
+				res = append(res, j)
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// FindByBranch 通过临时分支查找jira记录，不存在时返回nil
+func (s *Store) FindByBranch(project, branchName string) (j *Jira, err error) {
+	err = s.view(func(tx *bolt.Tx) (err error) {
+		key := tx.Bucket(bucketBranchIdx).Get([]byte(project + "\x00" + branchName))
+		if key == nil {
+			return
+		}
+		j, err = getJira(tx, key)
+		return
+	})
+	return
+}
+
+// Write 在一个事务中写入和删除jira记录，同时维护索引
+func (s *Store) Write(puts []*Jira, dels []*Jira) error {
+	return s.update(func(tx *bolt.Tx) (err error) {
+		for _, j := range dels {
+			if err = deleteJira(tx, j.Project, j.JiraID); err != nil {
+				return
+			}
+		}
+
+		for _, j := range puts {
+			if err = putJira(tx, j); err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// Status 数据库的版本信息，数据库不存在时视为最新版本
+func (s *Store) Status() (st *MigrationStatus, err error) {
+	version := JiraMigrator.Latest()
+	err = s.view(func(tx *bolt.Tx) error {
+		version = storeVersion(tx)
+		return nil
+	})
+	return JiraMigrator.status(s.path, version), err
+}
+
+// Migrate 将数据库升级到最新版本，升级前备份数据库文件
+func (s *Store) Migrate() (st *MigrationStatus, err error) {
+	if st, err = s.Status(); err != nil || len(st.Pending) == 0 {
+		return
+	}
+
+	err = s.update(func(tx *bolt.Tx) (err error) {
+		var (
+			list  []json.RawMessage
+			data  []byte
+			jiras []*Jira
+		)
+
+		//加锁后重新检查，其他进程可能已经完成升级
+		version := storeVersion(tx)
+		if version >= JiraMigrator.Latest() {
+			return
+		}
+
+		if err = tx.CopyFile(backupPath(s.path, version), 0644); err != nil {
+			return fmt.Errorf("备份%s失败:%v", s.path, err)
+		}
+
+		if err = tx.Bucket(bucketJira).ForEach(func(k, v []byte) error {
+			list = append(list, append(json.RawMessage{}, v...))
+			return nil
+		}); err != nil {
+			return
+		}
+
+		if data, err = json.Marshal(list); err != nil {
+			return
+		}
+		if data, err = JiraMigrator.Upgrade(data, version); err != nil {
+			return
+		}
+		if err = json.Unmarshal(data, &jiras); err != nil {
+			return
+		}
+
+		for _, j := range jiras {
+			if err = putJira(tx, j); err != nil {
+				return
+			}
+		}
+		return setStoreVersion(tx, JiraMigrator.Latest())
+	})
+	if err != nil {
+		return
+	}
+	return s.Status()
+}
+
+func (s *Store) view(fn func(tx *bolt.Tx) error) error {
+	//数据库不存在时视为空
+	if !s.Exists() {
+		return nil
+	}
+
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: s.timeout, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("打开%s失败:%v", s.path, err)
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketJira) == nil {
+			return nil
+		}
+		return fn(tx)
+	})
+}
+
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: s.timeout})
+	if err != nil {
+		return fmt.Errorf("打开%s失败:%v", s.path, err)
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketJira, bucketJiraIdx, bucketBranchIdx, bucketMeta} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		//新建的数据库为最新版本
+		if tx.Bucket(bucketMeta).Get(versionKey) == nil {
+			if k, _ := tx.Bucket(bucketJira).Cursor().First(); k == nil {
+				if err := setStoreVersion(tx, JiraMigrator.Latest()); err != nil {
+					return err
+				}
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// storeVersion 没有版本信息的数据库视为版本0
+func storeVersion(tx *bolt.Tx) int {
+	b := tx.Bucket(bucketMeta)
+	if b == nil {
+		return 0
+	}
+
+	version, _ := strconv.Atoi(string(b.Get(versionKey)))
+	return version
+}
+
+func setStoreVersion(tx *bolt.Tx, version int) error {
+	return tx.Bucket(bucketMeta).Put(versionKey, []byte(strconv.Itoa(version)))
+}
+
+func getJira(tx *bolt.Tx, key []byte) (*Jira, error) {
+	v := tx.Bucket(bucketJira).Get(key)
+	if v == nil {
+		return nil, nil
+	}
+	return decodeJira(v)
+}
+
+func decodeJira(v []byte) (j *Jira, err error) {
+	if err = json.Unmarshal(v, &j); err != nil {
+		return nil, fmt.Errorf("unmarshal jira error: %v", err)
+	}
+	return
+}
+
+func putJira(tx *bolt.Tx, j *Jira) (err error) {
+	var (
+		b []byte
+	)
+
+	//先删除旧记录的分支索引
+	if err = deleteJira(tx, j.Project, j.JiraID); err != nil {
+		return
+	}
+
+	if b, err = json.Marshal(j); err != nil {
+		return fmt.Errorf("marshal jira data error: %v", err)
+	}
+
+	key := jiraKey(j.Project, j.JiraID)
+	if err = tx.Bucket(bucketJira).Put(key, b); err != nil {
+		return
+	}
+	if err = tx.Bucket(bucketJiraIdx).Put([]byte(j.JiraID+"\x00"+j.Project), nil); err != nil {
+		return
+	}
+
+	for _, jb := range j.BranchList {
+		if jb.BranchName == "" {
+			continue
+		}
+		if err = tx.Bucket(bucketBranchIdx).Put([]byte(j.Project+"\x00"+jb.BranchName), key); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func deleteJira(tx *bolt.Tx, project, jiraID string) (err error) {
+	var (
+		old *Jira
+	)
+
+	key := jiraKey(project, jiraID)
+	if old, err = getJira(tx, key); err != nil || old == nil {
+		return
+	}
+
+	for _, jb := range old.BranchList {
+		if jb.BranchName == "" {
+			continue
+		}
+		branchKey := []byte(project + "\x00" + jb.BranchName)
+		if bytes.Equal(tx.Bucket(bucketBranchIdx).Get(branchKey), key) {
+			if err = tx.Bucket(bucketBranchIdx).Delete(branchKey); err != nil {
+				return
+			}
+		}
+	}
+
+	if err = tx.Bucket(bucketJiraIdx).Delete([]byte(jiraID + "\x00" + project)); err != nil {
+		return
+	}
+	return tx.Bucket(bucketJira).Delete(key)
+}
@@ -0,0 +1,176 @@
+package model
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	s := NewStore(filepath.Join(t.TempDir(), "jira.db"))
+
+	//数据库不存在时为空
+	list, err := s.All()
+	assert.Nil(t, err)
+	assert.Empty(t, list)
+	assert.False(t, s.Exists())
+
+	a := &Jira{Project: "work", JiraID: "VM-1", CreateTime: time.Now()}
+	a.Append(&JiraBranch{BranchName: "VM-1_x_dev", DevBranch: "feature", TargetBranch: "dev"})
+	b := &Jira{Project: "common", JiraID: "VM-1", CreateTime: time.Now().Add(time.Second)}
+	c := &Jira{Project: "work", JiraID: "VM-2", CreateTime: time.Now().Add(2 * time.Second)}
+	assert.Nil(t, s.Write([]*Jira{a, b, c}, nil))
+
+	list, err = s.All()
+	assert.Nil(t, err)
+	assert.Len(t, list, 3)
+	assert.Equal(t, "VM-2", list[2].JiraID)
+
+	list, err = s.ListByProject("work")
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+
+	list, err = s.ListByJiraID("VM-1")
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+
+	j, err := s.FindByBranch("work", "VM-1_x_dev")
+	assert.Nil(t, err)
+	assert.Equal(t, "VM-1", j.JiraID)
+
+	//更新记录时同步更新分支索引
+	a.BranchList[0].BranchName = "VM-1_y_dev"
+	assert.Nil(t, s.Write([]*Jira{a}, nil))
+	j, err = s.FindByBranch("work", "VM-1_x_dev")
+	assert.Nil(t, err)
+	assert.Nil(t, j)
+	j, err = s.FindByBranch("work", "VM-1_y_dev")
+	assert.Nil(t, err)
+	assert.NotNil(t, j)
+
+	assert.Nil(t, s.Write(nil, []*Jira{{Project: "work", JiraID: "VM-1"}}))
+	j, err = s.Get("work", "VM-1")
+	assert.Nil(t, err)
+	assert.Nil(t, j)
+	j, err = s.FindByBranch("work", "VM-1_y_dev")
+	assert.Nil(t, err)
+	assert.Nil(t, j)
+	list, err = s.ListByJiraID("VM-1")
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestJiraMgr_Migrate(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	old := []*Jira{{Project: "work", JiraID: "VM-1", TargetBranch: []string{"dev"}}}
+	b, _ := json.Marshal(old)
+	assert.Nil(t, os.MkdirAll(filepath.Join(home, ".patch"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(home, ".patch", "jira.json"), b, 0644))
+
+	jm, err := NewJiraMgr()
+	assert.Nil(t, err)
+	assert.Len(t, jm.JiraList, 1)
+	assert.Equal(t, []string{"dev"}, jm.JiraList[0].TargetBranch)
+
+	_, err = os.Stat(filepath.Join(home, ".patch", "jira.json"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(home, ".patch", "jira.json.bak"))
+	assert.Nil(t, err)
+}
+
+func TestLoadJiraMgr(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	//目录不存在时为空，且不会创建目录
+	jm, err := LoadJiraMgr()
+	assert.Nil(t, err)
+	assert.Empty(t, jm.JiraList)
+	_, err = os.Stat(filepath.Join(home, ".patch"))
+	assert.True(t, os.IsNotExist(err))
+
+	old := []*Jira{{Project: "work", JiraID: "VM-1", TargetBranch: []string{"dev"}}}
+	b, _ := json.Marshal(old)
+	assert.Nil(t, os.MkdirAll(filepath.Join(home, ".patch"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(home, ".patch", "jira.json"), b, 0644))
+
+	//读取未迁移的jira.json，不会迁移
+	jm, err = LoadJiraMgr()
+	assert.Nil(t, err)
+	assert.Len(t, jm.JiraList, 1)
+	assert.NotNil(t, jm.Save())
+	_, err = os.Stat(filepath.Join(home, ".patch", "jira.json"))
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(home, ".patch", "jira.db"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestJiraMgr_Save(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	jm1, err := NewJiraMgr()
+	assert.Nil(t, err)
+	jm1.GetOrCreate("work", "VM-1", CommitTypeJira, "")
+	jm1.GetOrCreate("work", "VM-2", CommitTypeJira, "")
+	assert.Nil(t, jm1.Save())
+
+	//两个进程分别修改不同的记录，不会互相覆盖
+	jm2, err := NewJiraMgr()
+	assert.Nil(t, err)
+	jm1.GetOrCreate("work", "VM-1", CommitTypeJira, "").AddTargetBranch([]string{"dev"})
+	jm2.GetOrCreate("work", "VM-2", CommitTypeJira, "").AddTargetBranch([]string{"qa"})
+	jm2.GetOrCreate("work", "VM-3", CommitTypeJira, "")
+	assert.Nil(t, jm1.Save())
+	assert.Nil(t, jm2.Save())
+
+	jm, err := NewJiraMgr()
+	assert.Nil(t, err)
+	assert.Len(t, jm.JiraList, 3)
+	assert.Equal(t, []string{"dev"}, jm.get("work", "VM-1").TargetBranch)
+	assert.Equal(t, []string{"qa"}, jm.get("work", "VM-2").TargetBranch)
+
+	assert.Nil(t, jm.DelJira("work", "VM-3"))
+	jm, err = NewJiraMgr()
+	assert.Nil(t, err)
+	assert.Len(t, jm.JiraList, 2)
+	assert.Nil(t, jm.FindByBranch("work", "x"))
+}
+
+func TestJiraMgr_SaveConflict(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	jm, err := NewJiraMgr()
+	assert.Nil(t, err)
+	j := jm.GetOrCreate("work", "VM-1", CommitTypeJira, "")
+	j.AddTargetBranch([]string{"dev", "qa"})
+	j.Append(&JiraBranch{BranchName: "VM-1_x_dev", DevBranch: "feature", TargetBranch: "dev"})
+	assert.Nil(t, jm.Save())
+
+	//定时清理和push同时修改同一条记录
+	cron, err := NewJiraMgr()
+	assert.Nil(t, err)
+	push, err := NewJiraMgr()
+	assert.Nil(t, err)
+
+	cron.get("work", "VM-1").BranchList[0].SetCleanup(CleanupDeleted, "")
+	assert.Nil(t, cron.Save())
+	push.get("work", "VM-1").Append(&JiraBranch{BranchName: "VM-1_x_qa", DevBranch: "feature", TargetBranch: "qa"})
+	assert.Nil(t, push.Save())
+
+	jm, err = NewJiraMgr()
+	assert.Nil(t, err)
+	j = jm.get("work", "VM-1")
+	assert.Len(t, j.BranchList, 2)
+	assert.Equal(t, CleanupDeleted, j.BranchList[0].Cleanup)
+	assert.Equal(t, "VM-1_x_qa", j.BranchList[1].BranchName)
+
+	//合并后的记录作为下次保存的基准
+	assert.Equal(t, CleanupDeleted, push.get("work", "VM-1").BranchList[0].Cleanup)
+	assert.Nil(t, push.Save())
+}
@@ -0,0 +1,174 @@
+package repo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goeoeo/gitx/util"
+	"github.com/sirupsen/logrus"
+)
+
+// AuditMrUnknown 未配置代码托管平台时无法获取MR，MR状态未知的分支不标记为待清理
+const AuditMrUnknown = "unknown"
+
+type (
+	// Auditor 检查项目的远程分支，找出没有清理的临时分支
+	Auditor struct {
+		project string
+		git     *GitRepo
+		parser  *TmpBranchParser
+	}
+
+	// AuditItem 远程分支的审计结果
+	AuditItem struct {
+		Project    string
+		Branch     string
+		TmpBranch  bool      //是否符合 tmp_branch_fmt
+		Target     string    //对比的目标分支，临时分支以MR或分支名中的目标分支为准，其他分支为默认分支
+		CommitTime time.Time //最后一次commit的时间
+		AgeDays    int       //最后一次commit距今的天数
+		Committer  string    //最后一次commit的提交人
+		Ahead      int       //目标分支中没有的commit数
+		Behind     int       //分支中没有的目标分支commit数
+		MrState    string    //最近一次MR的状态，没有MR时为空
+		MrUrl      string
+		Stale      bool   //没有MR或MR已合并、关闭的临时分支
+		Reason     string //标记为待清理的原因
+	}
+)
+
+func NewAuditor(project string, r *Repo, c *Config) (a *Auditor, err error) {
+	a = &Auditor{
+		project: project,
+		git:     NewGitRepo(r.Path, r.Url),
+	}
+	if a.parser, err = NewTmpBranchParser(c.Patch.TmpBranchFmt); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Audit 拉取并检查所有远程分支，按分支名排序
+func (a *Auditor) Audit(now time.Time) (items []*AuditItem, err error) {
+	var (
+		remote []string
+		mrs    = map[string][]*MergeRequest{}
+	)
+
+	if ret, err := ExecCmd(a.git.Path, "git", "fetch", "--prune", "origin"); err != nil {
+		return nil, fmt.Errorf("拉取远程分支失败:%s", ret.ErrStr)
+	}
+
+	ret, err := ExecCmd(a.git.Path, "git", "for-each-ref", "--sort=refname", "--format=%(refname)%09%(committerdate:unix)%09%(committername)", "refs/remotes/origin")
+	if err != nil {
+		return nil, fmt.Errorf("获取远程分支失败:%s", ret.ErrStr)
+	}
+
+	for _, line := range strings.Split(ret.Out, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 3 || fields[0] == "refs/remotes/origin/HEAD" {
+			continue
+		}
+
+		unix, _ := strconv.ParseInt(fields[1], 10, 64)
+		it := &AuditItem{
+			Project:    a.project,
+			Branch:     strings.TrimPrefix(fields[0], "refs/remotes/origin/"),
+			CommitTime: time.Unix(unix, 0),
+			Committer:  fields[2],
+		}
+		it.AgeDays = int(now.Sub(it.CommitTime).Hours() / 24)
+		items = append(items, it)
+		remote = append(remote, it.Branch)
+	}
+
+	if a.git.forge == nil {
+		logrus.Warnf("未配置代码托管平台，无法获取MR状态:%s", a.project)
+	} else if mrs, err = a.git.mergeRequestsBySource(); err != nil {
+		return
+	}
+
+	defaultBranch := a.defaultBranch()
+	for _, it := range items {
+		a.check(it, remote, mrs[it.Branch], defaultBranch)
+	}
+	return
+}
+
+func (a *Auditor) check(it *AuditItem, remote []string, mrs []*MergeRequest, defaultBranch string) {
+	var mr *MergeRequest
+	if n := len(mrs); n > 0 {
+		mr = mrs[n-1]
+		it.MrState, it.MrUrl = mr.State, mr.WebUrl
+	} else if a.git.forge == nil {
+		it.MrState = AuditMrUnknown
+	}
+
+	it.TmpBranch = a.parser.IsTmpBranch(it.Branch)
+	switch {
+	case mr != nil:
+		it.Target = mr.TargetBranch
+	case it.TmpBranch:
+		_, it.Target = a.parser.Parse(it.Branch, remote)
+	default:
+		it.Target = defaultBranch
+	}
+
+	if it.Target != "" && it.Target != it.Branch {
+		it.Ahead, it.Behind = a.git.aheadBehind("origin/"+it.Branch, "origin/"+it.Target)
+	}
+
+	if !it.TmpBranch {
+		return
+	}
+	switch it.MrState {
+	case "":
+		it.Stale, it.Reason = true, "没有MR"
+	case MrStateMerged:
+		it.Stale, it.Reason = true, "MR已合并"
+	case MrStateClosed:
+		it.Stale, it.Reason = true, "MR已关闭"
+	}
+}
+
+// defaultBranch 远程仓库的默认分支，无法获取时使用master
+func (a *Auditor) defaultBranch() string {
+	ret, err := ExecCmd(a.git.Path, "git", "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	if err != nil {
+		return "master"
+	}
+	return strings.TrimPrefix(strings.TrimSpace(ret.Out), "origin/")
+}
+
+// aheadBehind branch 相对 target 领先和落后的commit数，目标分支不存在时都为0
+func (g *GitRepo) aheadBehind(branch, target string) (ahead, behind int) {
+	ret, err := ExecCmd(g.Path, "git", "rev-list", "--left-right", "--count", fmt.Sprintf("%s...%s", target, branch), "--")
+	if err != nil {
+		logrus.Debugf("计算%s与%s的差异失败:%s", branch, target, ret.ErrStr)
+		return
+	}
+
+	fields := strings.Fields(ret.Out)
+	if len(fields) != 2 {
+		return
+	}
+	behind, _ = strconv.Atoi(fields[0])
+	ahead, _ = strconv.Atoi(fields[1])
+	return
+}
+
+// Row 表格、csv中的一行
+func (it *AuditItem) Row() []string {
+	stale := ""
+	if it.Stale {
+		stale = "待清理:" + it.Reason
+	}
+
+	return []string{it.Project, it.Branch, it.Target, strconv.Itoa(it.AgeDays), it.Committer,
+		strconv.Itoa(it.Ahead), strconv.Itoa(it.Behind), util.Default(it.MrState, "-"), it.MrUrl, stale}
+}
+
+// AuditHeader 与 AuditItem.Row 对应的表头
+var AuditHeader = []string{"项目", "分支", "目标分支", "天数", "提交人", "领先", "落后", "MR状态", "MR", "标记"}
@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditor_Audit(t *testing.T) {
+	dir := newTestRepo(t)
+	gitRun(t, dir, "checkout", "-b", "VM-1_x_dev", "origin/dev")
+	gitCommit(t, dir, "b.txt", "b\n", "VM-1 add b")
+	gitRun(t, dir, "push", "origin", "VM-1_x_dev")
+	gitRun(t, dir, "push", "origin", "VM-1_x_dev:VM-2_y_dev")
+	gitRun(t, dir, "checkout", "master")
+	gitCommit(t, dir, "c.txt", "c\n", "master only")
+	gitRun(t, dir, "push", "origin", "master")
+
+	a, err := NewAuditor("work", &Repo{Path: dir}, cfg)
+	assert.Nil(t, err)
+	a.git.forge = &stubForge{mrs: []*MergeRequest{
+		{Id: 1, State: MrStateOpened, SourceBranch: "VM-2_y_dev", TargetBranch: "dev", WebUrl: "mr/1"},
+	}}
+
+	items, err := a.Audit(time.Now().Add(72 * time.Hour))
+	assert.Nil(t, err)
+
+	byName := map[string]*AuditItem{}
+	for _, it := range items {
+		byName[it.Branch] = it
+	}
+	assert.Len(t, byName, 4)
+
+	it := byName["VM-1_x_dev"]
+	assert.True(t, it.TmpBranch)
+	assert.Equal(t, "dev", it.Target)
+	assert.Equal(t, 1, it.Ahead)
+	assert.Equal(t, 0, it.Behind)
+	assert.Equal(t, 3, it.AgeDays)
+	assert.Equal(t, "gitx", it.Committer)
+	assert.True(t, it.Stale)
+	assert.Equal(t, "没有MR", it.Reason)
+
+	it = byName["VM-2_y_dev"]
+	assert.Equal(t, MrStateOpened, it.MrState)
+	assert.False(t, it.Stale)
+
+	//非临时分支与默认分支对比
+	it = byName["dev"]
+	assert.False(t, it.TmpBranch)
+	assert.Equal(t, "master", it.Target)
+	assert.Equal(t, 0, it.Ahead)
+	assert.Equal(t, 1, it.Behind)
+	assert.False(t, it.Stale)
+}
+
+func TestAuditor_AuditNoForge(t *testing.T) {
+	dir := newTestRepo(t)
+	gitRun(t, dir, "checkout", "-b", "VM-1_x_dev", "origin/dev")
+	gitCommit(t, dir, "b.txt", "b\n", "VM-1 add b")
+	gitRun(t, dir, "push", "origin", "VM-1_x_dev")
+
+	a, err := NewAuditor("work", &Repo{Path: dir}, cfg)
+	assert.Nil(t, err)
+
+	//无法获取MR时状态未知，不标记为待清理
+	items, err := a.Audit(time.Now())
+	assert.Nil(t, err)
+	for _, it := range items {
+		if it.Branch == "VM-1_x_dev" {
+			assert.True(t, it.TmpBranch)
+			assert.Equal(t, AuditMrUnknown, it.MrState)
+			assert.False(t, it.Stale)
+		}
+	}
+}
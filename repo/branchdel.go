@@ -0,0 +1,300 @@
+package repo
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	BranchDelDeleted = "deleted" //已删除
+	BranchDelSkipped = "skipped" //跳过
+	BranchDelFailed  = "failed"  //删除失败
+)
+
+type (
+	// BranchFilter branchDel 选择分支的条件，多个条件同时满足时才会选中
+	BranchFilter struct {
+		Patterns   []string      //分支名规则：普通字符串模糊匹配，含 * ? 时为通配符，re: 开头为正则，多个规则满足一个即可
+		Remote     bool          //同时选择只存在于远程的分支
+		MergedInto string        //只选择已合入该分支的分支
+		OlderThan  time.Duration //只选择最后一次commit早于该时长的分支
+		Mine       bool          //只选择最后一次commit作者是自己的分支
+	}
+
+	// BranchCandidate 匹配到的分支
+	BranchCandidate struct {
+		Name       string
+		Local      bool      //本地分支是否存在
+		Remote     bool      //远程分支是否存在
+		CommitTime time.Time //最后一次commit的时间
+		Author     string    //最后一次commit的作者邮箱
+		Result     string
+		Reason     string
+	}
+)
+
+// Match 分支名是否满足规则，没有规则时都满足
+func (f *BranchFilter) Match(branch string) (bool, error) {
+	if len(f.Patterns) == 0 {
+		return true, nil
+	}
+
+	for _, pattern := range f.Patterns {
+		if !strings.HasPrefix(pattern, "re:") && !strings.ContainsAny(pattern, "*?") {
+			if strings.Contains(branch, pattern) {
+				return true, nil
+			}
+			continue
+		}
+
+		re, err := compileBranchPattern(pattern)
+		if err != nil {
+			return false, fmt.Errorf("无效的分支规则:%s,%v", pattern, err)
+		}
+		if re.MatchString(branch) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FindBranchs 拉取远程分支后按条件查找分支，本地分支优先使用本地的commit信息
+func (g *GitRepo) FindBranchs(f *BranchFilter, now time.Time) (cs []*BranchCandidate, err error) {
+	var (
+		byName = map[string]*BranchCandidate{}
+		email  string
+	)
+
+	//远程已删除的分支不再作为候选
+	if ret, err := ExecCmd(g.Path, "git", "fetch", "--prune", "origin"); err != nil {
+		logrus.Warnf("拉取远程分支失败:%s", strings.TrimSpace(ret.ErrStr))
+	}
+
+	if f.Mine {
+		if email, err = g.UserEmail(); err != nil {
+			return
+		}
+	}
+
+	mergedInto := f.MergedInto
+	if mergedInto != "" && !strings.HasPrefix(mergedInto, "origin/") && g.RefExists("origin/"+mergedInto) {
+		mergedInto = "origin/" + mergedInto
+	}
+
+	ret, err := ExecCmd(g.Path, "git", "for-each-ref", "--format=%(refname)%09%(committerdate:unix)%09%(authoremail)", "refs/heads", "refs/remotes/origin")
+	if err != nil {
+		return nil, fmt.Errorf("获取分支列表失败:%s", ret.ErrStr)
+	}
+
+	for _, line := range strings.Split(ret.Out, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 3 {
+			continue
+		}
+
+		var (
+			ref    = fields[0]
+			name   string
+			remote bool
+		)
+		switch {
+		case strings.HasPrefix(ref, "refs/heads/"):
+			name = strings.TrimPrefix(ref, "refs/heads/")
+		case ref == "refs/remotes/origin/HEAD":
+			continue
+		default:
+			name, remote = strings.TrimPrefix(ref, "refs/remotes/origin/"), true
+		}
+
+		c := byName[name]
+		if c == nil {
+			c = &BranchCandidate{Name: name}
+			byName[name] = c
+		}
+		if remote {
+			c.Remote = true
+			if c.Local {
+				continue
+			}
+		} else {
+			c.Local = true
+		}
+
+		unix, _ := strconv.ParseInt(fields[1], 10, 64)
+		c.CommitTime = time.Unix(unix, 0)
+		c.Author = strings.Trim(fields[2], "<>")
+	}
+
+	for _, c := range byName {
+		if !c.Local && !f.Remote {
+			continue
+		}
+		//合入的目标分支本身不删除
+		if f.MergedInto != "" && c.Name == strings.TrimPrefix(f.MergedInto, "origin/") {
+			continue
+		}
+
+		var ok bool
+		if ok, err = f.Match(c.Name); err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		if f.OlderThan > 0 && now.Sub(c.CommitTime) < f.OlderThan {
+			continue
+		}
+		if f.Mine && !strings.EqualFold(c.Author, email) {
+			continue
+		}
+		if mergedInto != "" && !g.IsAncestor(c.ref(), mergedInto) {
+			continue
+		}
+		cs = append(cs, c)
+	}
+
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].Name < cs[j].Name
+	})
+	return
+}
+
+// UserEmail 当前用户的邮箱，与commit作者的取值方式一致
+func (g *GitRepo) UserEmail() (string, error) {
+	ret, err := ExecCmd(g.Path, "git", "var", "GIT_AUTHOR_IDENT")
+	if err != nil {
+		return "", fmt.Errorf("获取用户邮箱失败:%s", ret.ErrStr)
+	}
+
+	out := ret.Out
+	start, end := strings.Index(out, "<"), strings.Index(out, ">")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("获取用户邮箱失败:%s", out)
+	}
+	return out[start+1 : end], nil
+}
+
+// DeleteBranch 删除分支的本地和远程分支，受保护的分支、当前分支、存在未合并MR的分支跳过
+// 未配置代码托管平台时无法检查MR，只删除本地分支，skipMrCheck时不检查MR直接删除远程分支
+// dryRun时只做检查，结果记录在 Result 和 Reason 中
+func (g *GitRepo) DeleteBranch(c *BranchCandidate, dryRun, skipMrCheck bool) {
+	c.Result, c.Reason = g.deleteBranch(c, dryRun, skipMrCheck)
+	logrus.Debugf("删除分支 %s:%s %s", c.Name, c.Result, c.Reason)
+}
+
+func (g *GitRepo) deleteBranch(c *BranchCandidate, dryRun, skipMrCheck bool) (result, reason string) {
+	var (
+		remote = c.Remote
+		notes  []string
+	)
+
+	if g.Protected(c.Name) {
+		return BranchDelSkipped, "受保护的分支"
+	}
+
+	if c.Local {
+		if current, _ := g.GetBranch(); current == c.Name {
+			return BranchDelSkipped, "当前所在的分支"
+		}
+	}
+
+	switch {
+	case remote && g.forge == nil && skipMrCheck:
+		notes = append(notes, "未配置代码托管平台，未检查MR")
+	case remote && g.forge == nil:
+		//无法确认是否存在未合并的MR时只删除本地分支
+		if !c.Local {
+			return BranchDelSkipped, "未配置代码托管平台，无法检查MR"
+		}
+		remote = false
+		notes = append(notes, "未配置代码托管平台，未删除远程分支")
+	case remote:
+		mrs, err := g.forge.ListMergeRequests(MrStateOpened, c.Name, "")
+		if err != nil {
+			return BranchDelFailed, fmt.Sprintf("获取MR失败:%v", err)
+		}
+		if len(mrs) > 0 {
+			return BranchDelSkipped, fmt.Sprintf("存在未合并的MR:%s", mrs[0].WebUrl)
+		}
+	}
+
+	if dryRun {
+		return BranchDelDeleted, strings.Join(notes, ";")
+	}
+
+	var errs []string
+	if remote {
+		if err := g.DelRemoteBranch(c.Name); err != nil {
+			errs = append(errs, fmt.Sprintf("删除远程分支失败:%v", err))
+		}
+	}
+	if c.Local {
+		if err := g.DelLocalBranch(c.Name); err != nil {
+			errs = append(errs, fmt.Sprintf("删除本地分支失败:%v", err))
+		}
+	}
+	if len(errs) > 0 {
+		return BranchDelFailed, strings.Join(append(errs, notes...), ";")
+	}
+	return BranchDelDeleted, strings.Join(notes, ";")
+}
+
+// ref 判断合并、读取commit时使用的引用，本地分支优先
+func (c *BranchCandidate) ref() string {
+	if c.Local {
+		return "refs/heads/" + c.Name
+	}
+	return "refs/remotes/origin/" + c.Name
+}
+
+// Where 分支所在的位置
+func (c *BranchCandidate) Where() string {
+	switch {
+	case c.Local && c.Remote:
+		return "本地+远程"
+	case c.Local:
+		return "本地"
+	default:
+		return "远程"
+	}
+}
+
+var selectionRe = regexp.MustCompile(`^(\d+)(?:-(\d+))?$`)
+
+// ParseSelection 解析交互时选择的序号，如 1,3-5，序号从1开始，返回从0开始的下标
+func ParseSelection(input string, n int) (idx []int, err error) {
+	seen := map[int]bool{}
+	for _, part := range strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ' '
+	}) {
+		m := selectionRe.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("无效的序号:%s", part)
+		}
+
+		from, _ := strconv.Atoi(m[1])
+		to := from
+		if m[2] != "" {
+			to, _ = strconv.Atoi(m[2])
+		}
+		if from < 1 || to > n || from > to {
+			return nil, fmt.Errorf("序号超出范围:%s", part)
+		}
+
+		for i := from - 1; i < to; i++ {
+			if !seen[i] {
+				seen[i] = true
+				idx = append(idx, i)
+			}
+		}
+	}
+	sort.Ints(idx)
+	return
+}
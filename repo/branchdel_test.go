@@ -0,0 +1,150 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBranchFilter_Match(t *testing.T) {
+	f := &BranchFilter{Patterns: []string{"VM-1", "feature/*", `re:^v\d+$`}}
+	for branch, want := range map[string]bool{
+		"VM-1_fix_dev": true,
+		"feature/a":    true,
+		"v6":           true,
+		"v6.1":         false,
+		"bugfix/a":     false,
+	} {
+		ok, err := f.Match(branch)
+		assert.Nil(t, err)
+		assert.Equal(t, want, ok, branch)
+	}
+
+	_, err := (&BranchFilter{Patterns: []string{"re:("}}).Match("dev")
+	assert.NotNil(t, err)
+}
+
+func TestGitRepo_FindBranchs(t *testing.T) {
+	dir := newTestRepo(t)
+	gitRun(t, dir, "checkout", "-b", "VM-1_merged")
+	gitRun(t, dir, "checkout", "-b", "VM-2_open")
+	gitCommit(t, dir, "b.txt", "b\n", "VM-2 add b")
+	gitRun(t, dir, "push", "origin", "VM-2_open")
+	gitRun(t, dir, "push", "origin", "VM-2_open:VM-3_remote")
+	gitRun(t, dir, "checkout", "master")
+	gitRun(t, dir, "branch", "other")
+	g := NewGitRepo(dir, "")
+
+	names := func(cs []*BranchCandidate) (ret []string) {
+		for _, c := range cs {
+			ret = append(ret, c.Name)
+		}
+		return
+	}
+
+	cs, err := g.FindBranchs(&BranchFilter{Patterns: []string{"VM-*"}}, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"VM-1_merged", "VM-2_open"}, names(cs))
+	assert.Equal(t, "本地", cs[0].Where())
+	assert.Equal(t, "本地+远程", cs[1].Where())
+	assert.Equal(t, "gitx@example.com", cs[1].Author)
+
+	cs, err = g.FindBranchs(&BranchFilter{Patterns: []string{"VM-"}, Remote: true, MergedInto: "master"}, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"VM-1_merged"}, names(cs))
+
+	//目标分支本身不是候选
+	cs, err = g.FindBranchs(&BranchFilter{MergedInto: "master"}, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"VM-1_merged", "other"}, names(cs))
+
+	cs, err = g.FindBranchs(&BranchFilter{Patterns: []string{"VM-"}, Remote: true, Mine: true}, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"VM-1_merged", "VM-2_open", "VM-3_remote"}, names(cs))
+
+	cs, err = g.FindBranchs(&BranchFilter{Patterns: []string{"VM-"}, OlderThan: 24 * time.Hour}, time.Now().Add(48*time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, cs, 2)
+	cs, err = g.FindBranchs(&BranchFilter{Patterns: []string{"VM-"}, OlderThan: 24 * time.Hour}, time.Now())
+	assert.Nil(t, err)
+	assert.Len(t, cs, 0)
+}
+
+func TestGitRepo_DeleteBranch(t *testing.T) {
+	dir := newTestRepo(t)
+	gitRun(t, dir, "branch", "qa")
+	gitRun(t, dir, "branch", "feature")
+	gitRun(t, dir, "push", "origin", "feature")
+	g := NewGitRepo(dir, "")
+
+	cs, err := g.FindBranchs(&BranchFilter{Patterns: []string{"qa", "feature", "master"}}, time.Now())
+	assert.Nil(t, err)
+	assert.Len(t, cs, 3)
+
+	g.forge = &stubForge{}
+	for _, c := range cs {
+		g.DeleteBranch(c, true, false)
+	}
+	assert.Equal(t, BranchDelDeleted, cs[0].Result)
+	assert.Equal(t, BranchDelSkipped, cs[1].Result)
+	assert.Equal(t, BranchDelSkipped, cs[2].Result)
+	assert.True(t, g.RefExists("refs/heads/feature"))
+
+	//存在未合并的MR时跳过
+	g.forge = &stubForge{mrs: []*MergeRequest{{Id: 1, State: MrStateOpened, SourceBranch: "feature", WebUrl: "mr/1"}}}
+	g.DeleteBranch(cs[0], false, false)
+	assert.Equal(t, BranchDelSkipped, cs[0].Result)
+	assert.Equal(t, "存在未合并的MR:mr/1", cs[0].Reason)
+
+	g.forge = &stubForge{}
+	g.DeleteBranch(cs[0], false, false)
+	assert.Equal(t, BranchDelDeleted, cs[0].Result)
+	assert.False(t, g.RefExists("refs/heads/feature"))
+	assert.False(t, g.RefExists("refs/remotes/origin/feature"))
+}
+
+func TestGitRepo_DeleteBranchNoForge(t *testing.T) {
+	dir := newTestRepo(t)
+	for _, b := range []string{"feature", "other"} {
+		gitRun(t, dir, "branch", b)
+		gitRun(t, dir, "push", "origin", b)
+	}
+	gitRun(t, dir, "push", "origin", "master:remote-only")
+	g := NewGitRepo(dir, "")
+
+	cs, err := g.FindBranchs(&BranchFilter{Patterns: []string{"feature", "other", "remote-only"}, Remote: true}, time.Now())
+	assert.Nil(t, err)
+	assert.Len(t, cs, 3)
+
+	//未配置代码托管平台时只删除本地分支，只存在于远程的分支跳过
+	g.DeleteBranch(cs[0], false, false)
+	assert.Equal(t, BranchDelDeleted, cs[0].Result)
+	assert.Equal(t, "未配置代码托管平台，未删除远程分支", cs[0].Reason)
+	assert.False(t, g.RefExists("refs/heads/feature"))
+	assert.True(t, g.RefExists("refs/remotes/origin/feature"))
+
+	g.DeleteBranch(cs[2], false, false)
+	assert.Equal(t, BranchDelSkipped, cs[2].Result)
+	assert.True(t, g.RefExists("refs/remotes/origin/remote-only"))
+
+	//指定跳过MR检查时同时删除远程分支
+	g.DeleteBranch(cs[1], false, true)
+	assert.Equal(t, BranchDelDeleted, cs[1].Result)
+	assert.Equal(t, "未配置代码托管平台，未检查MR", cs[1].Reason)
+	assert.False(t, g.RefExists("refs/heads/other"))
+	assert.False(t, g.RefExists("refs/remotes/origin/other"))
+}
+
+func TestParseSelection(t *testing.T) {
+	idx, err := ParseSelection("3-4, 1,4", 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 2, 3}, idx)
+
+	_, err = ParseSelection("0", 5)
+	assert.NotNil(t, err)
+	_, err = ParseSelection("4-6", 5)
+	assert.NotNil(t, err)
+	_, err = ParseSelection("a", 5)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,284 @@
+package repo
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/goeoeo/gitx/util"
+	"github.com/sirupsen/logrus"
+)
+
+// 预测cherry-pick的结果
+const (
+	CheckClean    = "clean"           //可以干净的cherry-pick
+	CheckConflict = "conflict"        //会冲突
+	CheckPresent  = "already-present" //目标分支中已包含该修改
+)
+
+type (
+	// RepoCheck 项目所有目标分支的cherry-pick预测结果
+	RepoCheck struct {
+		Project  string
+		Branches []*BranchCheck
+	}
+
+	// BranchCheck 单个目标分支的预测结果
+	BranchCheck struct {
+		TgtBranch string
+		Commits   []*CommitCheck //按cherry-pick的顺序排列
+		Error     string
+	}
+
+	CommitCheck struct {
+		Commit *model.CommitInfo
+		State  string
+	}
+)
+
+// SimulateCherryPick 在临时index中依次应用commit，预测cherry-pick到base的结果，不会修改工作区、分支和index
+// 冲突的commit不会被应用，后续commit基于冲突前的状态继续预测
+func (g *GitRepo) SimulateCherryPick(base string, commits []string) (states []string, err error) {
+	var (
+		dir     string
+		ret     *CmdRet
+		before  string
+		state   string
+		indexFn string
+	)
+
+	if dir, err = os.MkdirTemp("", "gitx-check-"); err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	indexFn = filepath.Join(dir, "index")
+	env := []string{"GIT_INDEX_FILE=" + indexFn}
+	git := func(stdin string, arg ...string) (*CmdRet, error) {
+		return ExecCmdEnv(g.Path, env, stdin, "git", arg...)
+	}
+
+	if ret, err = git("", "read-tree", base); err != nil {
+		return nil, fmt.Errorf("read-tree %s 失败:%s", base, ret.ErrStr)
+	}
+
+	for _, commit := range commits {
+		if ret, err = git("", "write-tree"); err != nil {
+			return nil, fmt.Errorf("write-tree 失败:%s", ret.ErrStr)
+		}
+		before = strings.TrimSpace(ret.Out)
+
+		if ret, err = git("", "diff-tree", "-p", "--binary", "--root", "--no-commit-id", commit); err != nil {
+			return nil, fmt.Errorf("读取commit失败:%s,%s", commit, ret.ErrStr)
+		}
+
+		state = CheckPresent
+		if strings.TrimSpace(ret.Out) != "" {
+			state, err = g.simulateApply(git, ret.Out, before)
+			if err != nil {
+				return
+			}
+		}
+
+		logrus.Debugf("simulate cherry-pick %s onto %s: %s", commit, base, state)
+		states = append(states, state)
+	}
+	return
+}
+
+// simulateApply 应用单个commit的patch，冲突时还原index
+func (g *GitRepo) simulateApply(git func(stdin string, arg ...string) (*CmdRet, error), patch, before string) (state string, err error) {
+	var (
+		ret *CmdRet
+	)
+
+	if _, aErr := git(patch, "apply", "--cached", "--3way", "-"); aErr != nil {
+		if ret, err = git("", "read-tree", before); err != nil {
+			return "", fmt.Errorf("还原index失败:%s", ret.ErrStr)
+		}
+
+		//能反向应用，说明目标分支已包含该修改，如新增的文件已存在
+		if _, rErr := git(patch, "apply", "--cached", "--check", "-R", "-"); rErr == nil {
+			return CheckPresent, nil
+		}
+		return CheckConflict, nil
+	}
+
+	if ret, err = git("", "write-tree"); err != nil {
+		return "", fmt.Errorf("write-tree 失败:%s", ret.ErrStr)
+	}
+
+	//应用后内容没有变化，说明目标分支已包含该修改
+	if strings.TrimSpace(ret.Out) == before {
+		return CheckPresent, nil
+	}
+	return CheckClean, nil
+}
+
+// Check 预测所有目标分支cherry-pick的结果
+func (rp *RepoPatch) Check() (rc *RepoCheck, err error) {
+	var (
+		jm *model.JiraMgr
+	)
+
+	if jm, err = model.LoadJiraMgr(); err != nil {
+		return
+	}
+
+	//只读，不保存
+	jira := jm.GetOrCreate(rp.Repo.Name, rp.Patch.JiraId, rp.Patch.CommitType, rp.Patch.CommitMsg)
+
+	rc = &RepoCheck{Project: rp.Repo.Name}
+	for _, tgtBranch := range rp.Patch.GetTgtBranchs() {
+		pRepo := NewRepoPush(rp.Repo, rp.config, tgtBranch, jira, rp.ignoreLocalCommit)
+		rc.Branches = append(rc.Branches, pRepo.check())
+	}
+	return
+}
+
+func (r *RepoPush) check() *BranchCheck {
+	var (
+		all     []*model.CommitInfo
+		present map[string]bool
+		commits []string
+		states  []string
+		err     error
+	)
+
+	tgtBranch := r.RepoPushPatch.TgtBranch
+	bc := &BranchCheck{TgtBranch: tgtBranch}
+
+	if r.RepoPushPatch.DevBranch == tgtBranch {
+		bc.Error = fmt.Sprintf("当前分支与目标分支不能是一个%s", tgtBranch)
+		return bc
+	}
+
+	if err = r.GitRepo.FetchBranch(tgtBranch); err != nil {
+		bc.Error = fmt.Sprintf("拉取目标分支失败:%s", tgtBranch)
+		return bc
+	}
+
+	if all, present, err = r.jiraCommits(); err != nil {
+		bc.Error = err.Error()
+		return bc
+	}
+
+	//git log 为倒序，按时间正序 cherry-pick
+	//目标分支中已有或已推送过的commit不参与预测，直接标记为已包含
+	for i := len(all) - 1; i >= 0; i-- {
+		cc := &CommitCheck{Commit: all[i]}
+		if present[all[i].CommitId] {
+			cc.State = CheckPresent
+		} else {
+			commits = append(commits, all[i].CommitId)
+		}
+		bc.Commits = append(bc.Commits, cc)
+	}
+
+	if len(commits) == 0 {
+		return bc
+	}
+	if states, err = r.GitRepo.SimulateCherryPick("origin/"+tgtBranch, commits); err != nil {
+		bc.Error = err.Error()
+		return bc
+	}
+
+	for _, cc := range bc.Commits {
+		if cc.State == "" {
+			cc.State, states = states[0], states[1:]
+		}
+	}
+	return bc
+}
+
+// HasConflict 是否有目标分支会冲突或无法预测
+func (rc *RepoCheck) HasConflict() bool {
+	for _, bc := range rc.Branches {
+		if bc.Error != "" {
+			return true
+		}
+		for _, cc := range bc.Commits {
+			if cc.State == CheckConflict {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Print 打印 commit × 目标分支 的预测矩阵
+func (rc *RepoCheck) Print() {
+	var (
+		header = []string{"项目", "commit", "描述"}
+		ids    []string
+		descs  = map[string]string{}
+		states = map[string]map[string]string{}
+		rows   [][]string
+	)
+
+	for _, bc := range rc.Branches {
+		header = append(header, bc.TgtBranch)
+		states[bc.TgtBranch] = map[string]string{}
+		for _, cc := range bc.Commits {
+			if _, ok := descs[cc.Commit.CommitId]; !ok {
+				ids = append(ids, cc.Commit.CommitId)
+				descs[cc.Commit.CommitId] = strings.Replace(cc.Commit.Desc, " ", "", -1)
+			}
+			states[bc.TgtBranch][cc.Commit.CommitId] = cc.State
+		}
+	}
+
+	for _, id := range ids {
+		row := []string{rc.Project, shortCommitId(id), descs[id]}
+		for _, bc := range rc.Branches {
+			row = append(row, util.Default(states[bc.TgtBranch][id], "-"))
+		}
+		rows = append(rows, row)
+	}
+
+	util.PrintTable(rows, header)
+
+	for _, bc := range rc.Branches {
+		if bc.Error != "" {
+			fmt.Printf("%s %s 无法预测:%s\n", rc.Project, bc.TgtBranch, bc.Error)
+		}
+	}
+}
+
+// confirmCheck push前预测冲突，存在冲突时由用户确认是否继续
+func (rp *RepoPatch) confirmCheck() (err error) {
+	var (
+		rc *RepoCheck
+	)
+
+	if rc, err = rp.Check(); err != nil {
+		return
+	}
+
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	rc.Print()
+	if !rc.HasConflict() || rp.Patch.AssumeYes {
+		return
+	}
+
+loop:
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Println("预测到 cherry-pick 冲突，输入 y 继续， 输入 n 程序退出。")
+	char, _, err := reader.ReadRune()
+	if err != nil {
+		return err
+	}
+	switch char {
+	case 'y':
+		return nil
+	case 'n':
+		return ErrStop
+	default:
+		goto loop
+	}
+}
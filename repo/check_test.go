@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitRepo_SimulateCherryPick(t *testing.T) {
+	_, dir := newConflictRepo(t)
+	change := gitRun(t, dir, "rev-parse", "feature^")
+	add := gitRun(t, dir, "rev-parse", "feature")
+
+	//master上已有相同的修改
+	gitRun(t, dir, "checkout", "master")
+	gitCommit(t, dir, "b.txt", "b\n", "add b")
+	gitRun(t, dir, "push", "origin", "master")
+	gitRun(t, dir, "checkout", "feature")
+
+	g := NewGitRepo(dir, "")
+	states, err := g.SimulateCherryPick("origin/dev", []string{change, add})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{CheckConflict, CheckClean}, states)
+
+	states, err = g.SimulateCherryPick("origin/master", []string{change, add})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{CheckClean, CheckPresent}, states)
+
+	//不影响工作区和index
+	assert.Equal(t, "feature", AutoBranch(dir))
+	assert.Equal(t, "", gitRun(t, dir, "status", "--porcelain"))
+}
+
+func TestRepoPatch_Check(t *testing.T) {
+	r, _ := newConflictRepo(t)
+
+	rc, err := NewRepoPatch(r, cfg).Check()
+	assert.Nil(t, err)
+	assert.True(t, rc.HasConflict())
+	assert.Len(t, rc.Branches, 2)
+	assert.Equal(t, "dev", rc.Branches[0].TgtBranch)
+	assert.Equal(t, CheckConflict, rc.Branches[0].Commits[0].State)
+	assert.Equal(t, "VM-1 change a", rc.Branches[0].Commits[0].Commit.Desc)
+	assert.Equal(t, CheckClean, rc.Branches[1].Commits[0].State)
+	assert.Equal(t, CheckClean, rc.Branches[1].Commits[1].State)
+}
+
+func TestRepoPatch_CheckPresent(t *testing.T) {
+	r, dir := newConflictRepo(t)
+
+	//master中已有相同修改的commit也列出
+	gitRun(t, dir, "checkout", "master")
+	gitRun(t, dir, "cherry-pick", "feature")
+	gitRun(t, dir, "push", "origin", "master")
+	gitRun(t, dir, "checkout", "feature")
+
+	rc, err := NewRepoPatch(r, cfg).Check()
+	assert.Nil(t, err)
+	commits := rc.Branches[1].Commits
+	assert.Len(t, commits, 2)
+	assert.Equal(t, "VM-1 change a", commits[0].Commit.Desc)
+	assert.Equal(t, CheckClean, commits[0].State)
+	assert.Equal(t, "VM-1 add b", commits[1].Commit.Desc)
+	assert.Equal(t, CheckPresent, commits[1].State)
+}
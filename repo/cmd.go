@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"github.com/sirupsen/logrus"
+	"os"
 	"os/exec"
 	"strings"
 )
@@ -31,6 +32,20 @@ func ExecCmd(dir string, name string, arg ...string) (*CmdRet, error) {
 	return execC(context.Background(), cmd)
 }
 
+// ExecCmdEnv 追加环境变量执行命令，stdin不为空时作为标准输入，不打印错误输出
+func ExecCmdEnv(dir string, env []string, stdin string, name string, arg ...string) (*CmdRet, error) {
+	logrus.Debugf("cmd: dir: [%s], env: %v, cmd:%s %s \n", dir, env, name, strings.Join(arg, " "))
+	cmd := exec.Command(name, arg...)
+	if len(dir) > 0 {
+		cmd.Dir = dir
+	}
+	cmd.Env = append(os.Environ(), env...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	return execC(context.WithValue(context.Background(), "print", false), cmd)
+}
+
 func execC(ctx context.Context, cmd *exec.Cmd) (*CmdRet, error) {
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
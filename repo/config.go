@@ -11,6 +11,7 @@ import (
 
 	nested "github.com/antonfisher/nested-logrus-formatter"
 	"github.com/goeoeo/gitx/model"
+	"github.com/goeoeo/gitx/tracker"
 	"github.com/goeoeo/gitx/util"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
@@ -19,16 +20,22 @@ import (
 var cfg *Config
 
 type Config struct {
-	Repo            map[string]*Repo `yaml:"repo"`
-	Patch           *Patch           `yaml:"patch"`
-	HomeDir         string           `yaml:"home_dir"`
-	LogLevel        int              `yaml:"log_level"`
-	GitLabConfigs   []*GitLabConfig  `yaml:"gitLab_configs"`
-	pwd             string
-	logBuffer       bytes.Buffer
-	projectRepoUrl  map[string]*Repo //存储project对应的repo地址
-	DisableInitLog  bool
-	EnableLogOutput bool //是否启用日志输出到标准输出
+	Repo              map[string]*Repo           `yaml:"repo"`
+	Patch             *Patch                     `yaml:"patch"`
+	HomeDir           string                     `yaml:"home_dir"`
+	LogLevel          int                        `yaml:"log_level"`
+	GitLabConfigs     []*GitLabConfig            `yaml:"gitLab_configs"`
+	GiteaConfigs      []*GitLabConfig            `yaml:"gitea_configs"` //Gitea/Forgejo
+	Jira              *tracker.JiraConfig        `yaml:"jira"`
+	GitLabIssue       *tracker.GitLabIssueConfig `yaml:"gitlab_issue"`       //使用GitLab issues跟踪任务
+	Retention         *Retention                 `yaml:"retention"`          //jira clear 清理临时分支的保留策略
+	ProtectedBranches []string                   `yaml:"protected_branches"` //受保护的分支，支持通配符和 re: 开头的正则
+	WorkspaceConfig   *Workspace                 `yaml:"workspace"`          //gitx workspace 克隆仓库的目录结构
+	pwd               string
+	logBuffer         bytes.Buffer
+	projectRepoUrl    map[string]*Repo //存储project对应的repo地址
+	DisableInitLog    bool
+	EnableLogOutput   bool //是否启用日志输出到标准输出
 }
 
 type Repo struct {
@@ -38,6 +45,9 @@ type Repo struct {
 	CreateMr            bool                `yaml:"create_mr"`              //自动创建mr
 	AutoMergeBranchList []string            `yaml:"auto_merge_branch_list"` //自动合并的分支
 	AutoMergeBranchHook map[string][]string `yaml:"auto_merge_branch_hook"` //自动合并分支后触发的操作
+	ShareTracking       bool                `yaml:"share_tracking"`         //将jira记录共享到仓库的 refs/gitx/jira 中
+	Retention           *Retention          `yaml:"retention"`              //覆盖全局的临时分支保留策略
+	ProtectedBranches   []string            `yaml:"protected_branches"`     //项目中额外受保护的分支
 }
 
 type Patch struct {
@@ -53,11 +63,31 @@ type Patch struct {
 	JiraProjects      []string          `yaml:"jira_projects"`   //jira项目，用于推断CommitType
 	TmpBranchFmt      string            `yaml:"tmp_branch_fmt"`  //临时分支的格式默认：{jiraID}_{jiraDesc}_{tgtBranch}
 	AutoMergeHook     bool              `yaml:"auto_merge_hook"` // 是否执行hook
+	AssumeYes         bool              `yaml:"assume_yes"`      //跳过commit确认
+	ConflictPolicy    string            `yaml:"conflict_policy"` //cherry-pick冲突的处理策略:abort,skip,pause,fail-branch，为空时交互式处理
+	SkipCheck         bool              `yaml:"skip_check"`      //push前不预测cherry-pick冲突
+	IssueBranch       *IssueBranch      `yaml:"issue_branch"`    //未指定目标分支时，从Jira任务的版本和标签推导
+	Issue             *tracker.Issue    `yaml:"-"`               //从Jira获取的任务信息
+}
+
+// IssueBranch Jira任务的版本、标签对应的目标分支，分支可以使用BranchAlias中的别名
+type IssueBranch struct {
+	FixVersions     map[string]string `yaml:"fix_versions"`
+	AffectsVersions map[string]string `yaml:"affects_versions"`
+	Labels          map[string]string `yaml:"labels"`
+}
+
+// BranchSource 从Jira任务推导出的目标分支及其来源
+type BranchSource struct {
+	Branch string
+	Source string
 }
 
 type GitLabConfig struct {
 	BaseUrl string `yaml:"base_url"` //https://git.internal.yunify.com
 	Token   string `yaml:"token"`
+	Type    string `yaml:"type"`    //代码托管平台:gitlab(默认),github,gitea
+	ApiUrl  string `yaml:"api_url"` //API地址，github默认为https://api.github.com，gitea默认为{base_url}/api/v1
 }
 
 func GetConfig(configPaths ...string) *Config {
@@ -176,10 +206,6 @@ func (c *Config) Init() *Config {
 		return nil
 	}
 
-	if c.Patch.JiraDesc == "" {
-		c.Patch.JiraDesc = "x"
-	}
-
 	if c.Patch.CurrentProject == "" {
 		for k, v := range c.Repo {
 			if v.Path == c.pwd {
@@ -211,6 +237,15 @@ func (c *Config) GetGitLabConfig(url string) *GitLabConfig {
 			return v
 		}
 	}
+
+	//返回副本，不修改配置中的项
+	for _, v := range c.GiteaConfigs {
+		if strings.HasPrefix(url, v.BaseUrl) {
+			gc := *v
+			gc.Type = ForgeGitea
+			return &gc
+		}
+	}
 	return nil
 }
 
@@ -238,19 +273,89 @@ func (c *Config) ParseJIRA(jiraID string) *Config {
 
 	//自动解析jiraID
 	if c.Patch.JiraId == "" {
-		c.Patch.JiraId, c.Patch.CommitType, c.Patch.CommitMsg = AutoJiraID(c.pwd, c.Patch.JiraProjects, "")
-		return c
+		c.Patch.JiraId, c.Patch.CommitType, c.Patch.CommitMsg = AutoJiraID(c.pwd, c.Trackers(nil), "")
+	} else if regexp.MustCompile(`^[a-z0-9]+$`).MatchString(c.Patch.JiraId) {
+		//通过commit挑选
+		c.Patch.JiraId, c.Patch.CommitType, c.Patch.CommitMsg = AutoJiraID(c.pwd, c.Trackers(nil), c.Patch.JiraId)
 	}
 
-	//通过commit挑选
-	re := regexp.MustCompile(`^[a-z0-9]+$`)
-	if re.MatchString(c.Patch.JiraId) {
-		c.Patch.JiraId, c.Patch.CommitType, c.Patch.CommitMsg = AutoJiraID(c.pwd, c.Patch.JiraProjects, c.Patch.JiraId)
-		return c
+	c.fetchIssue()
+	if c.Patch.JiraDesc == "" {
+		c.Patch.JiraDesc = "x"
 	}
-
 	return c
+}
+
+// Trackers 配置的任务跟踪系统，按顺序从commit message中提取任务key
+// 未配置GitLab issues的项目时使用仓库r对应的项目，r为空时使用当前目录的仓库
+func (c *Config) Trackers(r *Repo) (res []tracker.Tracker) {
+	var (
+		projects []string
+	)
 
+	if c.Patch != nil {
+		projects = c.Patch.JiraProjects
+	}
+	res = append(res, tracker.NewJira(c.Jira, projects))
+
+	if c.GitLabIssue != nil {
+		gc := *c.GitLabIssue
+		if glc := c.GetGitLabConfig(gc.BaseUrl); gc.Token == "" && glc != nil {
+			gc.Token = glc.Token
+		}
+		if r == nil {
+			r, _ = c.CurrentRepo()
+		}
+		if gc.Project == "" && r != nil {
+			gc.Project = projectPath(gc.BaseUrl, r.Url)
+		}
+		res = append(res, tracker.NewGitLabIssues(&gc))
+	}
+	return
+}
+
+// Tracker key所属并且配置了API的任务跟踪系统，没有时返回nil
+func (c *Config) Tracker(r *Repo, key string) tracker.Tracker {
+	t := tracker.Find(c.Trackers(r), key)
+	if t == nil || !t.Enabled() {
+		return nil
+	}
+	return t
+}
+
+// fetchIssue 配置了任务跟踪系统时获取任务的标题和状态，未指定jira_desc时用标题生成临时分支的描述
+func (c *Config) fetchIssue() {
+	t := c.Tracker(nil, c.Patch.JiraId)
+	if t == nil || c.Patch.JiraId == "" || c.Patch.CommitType != model.CommitTypeJira {
+		return
+	}
+
+	issue, err := t.GetIssue(c.Patch.JiraId)
+	if err != nil {
+		logrus.Warnf("获取任务失败:%s,%v", c.Patch.JiraId, err)
+		return
+	}
+
+	c.Patch.Issue = issue
+	if c.Patch.JiraDesc == "" {
+		c.Patch.JiraDesc = tracker.Slug(issue.Summary)
+	}
+}
+
+// ResolveIssue 任务的所有目标分支合入后按配置流转任务，返回任务记录是否有变化
+func (c *Config) ResolveIssue(j *model.Jira) bool {
+	t := c.Tracker(c.Repo[j.Project], j.JiraID)
+	if t == nil || j.CommitType != model.CommitTypeJira || j.Resolved || !j.Complete() {
+		return false
+	}
+
+	if err := t.Resolve(j.JiraID, j.TargetBranch); err != nil {
+		logrus.Warnf("流转任务失败:%s,%v", j.JiraID, err)
+		return false
+	}
+
+	j.Resolved = true
+	return true
 }
 
 func (c *Config) InitLog() {
@@ -289,11 +394,14 @@ func (c *Config) CheckErr(err error) {
 	logrus.Fatalf("err:%s\n", err)
 }
 
-func (c *Config) readProjectRepoUrl() (err error) {
-	projectRepoUrlFile := filepath.Join(c.HomeDir, "repo.json")
+// RepoFile 记录项目仓库信息的repo.json
+func (c *Config) RepoFile() *model.VersionedFile {
+	return &model.VersionedFile{Path: filepath.Join(c.HomeDir, "repo.json"), Migrator: model.RepoMigrator}
+}
 
+func (c *Config) readProjectRepoUrl() (err error) {
 	c.projectRepoUrl = make(map[string]*Repo)
-	if err = util.ReadJsonFile(projectRepoUrlFile, &c.projectRepoUrl); err != nil {
+	if err = c.RepoFile().Read(&c.projectRepoUrl); err != nil {
 		return
 	}
 
@@ -310,8 +418,7 @@ func (c *Config) readProjectRepoUrl() (err error) {
 }
 
 func (c *Config) writeProjectRepoUrl() (err error) {
-	projectRepoUrlFile := filepath.Join(c.HomeDir, "repo.json")
-	return util.WriteJsonFile(projectRepoUrlFile, &c.projectRepoUrl)
+	return c.RepoFile().Write(&c.projectRepoUrl)
 }
 
 func (p *Patch) GetTgtBranchs() (res []string) {
@@ -340,6 +447,61 @@ func (p *Patch) GetPlanTgtBranchList() (res []string) {
 	return
 }
 
+// IssueBranchs 根据Jira任务的 fixVersion、affectsVersion、label 推导目标分支，按任务中的顺序去重
+func (p *Patch) IssueBranchs() (res []*BranchSource) {
+	var (
+		exists = map[string]bool{}
+	)
+
+	if p.IssueBranch == nil || p.Issue == nil {
+		return
+	}
+
+	add := func(mapping map[string]string, values []string, field string) {
+		for _, v := range values {
+			branch, ok := mapping[v]
+			if !ok || exists[branch] {
+				continue
+			}
+			exists[branch] = true
+			res = append(res, &BranchSource{Branch: branch, Source: fmt.Sprintf("%s %s", field, v)})
+		}
+	}
+
+	add(p.IssueBranch.FixVersions, p.Issue.FixVersions, "fixVersion")
+	add(p.IssueBranch.AffectsVersions, p.Issue.AffectsVersions, "affectsVersion")
+	add(p.IssueBranch.Labels, p.Issue.Labels, "label")
+	return
+}
+
+// ResolveIssueBranchs 未指定目标分支时，使用从Jira任务推导出的分支作为目标分支，并加入计划要推的分支
+func (p *Patch) ResolveIssueBranchs() (res []*BranchSource) {
+	if len(p.TgtBranchs) > 0 {
+		return
+	}
+
+	res = p.IssueBranchs()
+	for _, v := range res {
+		p.TgtBranchs = append(p.TgtBranchs, v.Branch)
+	}
+
+	p.PlanTgtBranchList = util.Unique(append(p.PlanTgtBranchList, p.TgtBranchs...))
+	return
+}
+
+// PrintBranchSources 打印目标分支及其来源
+func PrintBranchSources(jiraId string, sources []*BranchSource, alias map[string]string) {
+	var rows [][]string
+	for _, v := range sources {
+		branch := v.Branch
+		if tgt, ok := alias[v.Branch]; ok {
+			branch = fmt.Sprintf("%s(%s)", tgt, v.Branch)
+		}
+		rows = append(rows, []string{jiraId, branch, v.Source})
+	}
+	util.PrintTable(rows, []string{"JiraID", "目标分支", "来源"})
+}
+
 // TransBranch 翻译分支名
 func (c *Config) TransBranch(branchList []string) (res []string) {
 	for _, branchName := range branchList {
@@ -2,7 +2,13 @@ package repo
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/goeoeo/gitx/tracker"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestConfig_Init(t *testing.T) {
@@ -10,5 +16,98 @@ func TestConfig_Init(t *testing.T) {
 	r, err := config.CurrentRepo()
 	config.CheckErr(err)
 	fmt.Println("repo url is: ", r.Url)
-	
+
+}
+
+func TestConfig_ResolveIssue(t *testing.T) {
+	var transitions int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"transitions":[{"id":"31","name":"Resolved"}]}`))
+			return
+		}
+		transitions++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c := &Config{Jira: &tracker.JiraConfig{BaseUrl: server.URL, Workflow: &tracker.WorkflowConfig{ResolveTransition: "Resolved"}}}
+	j := &model.Jira{JiraID: "VM-1", CommitType: model.CommitTypeJira, TargetBranch: []string{"dev", "qa"}}
+	j.Append(&model.JiraBranch{DevBranch: "feature", TargetBranch: "dev", Merged: true})
+	j.Append(&model.JiraBranch{DevBranch: "feature", TargetBranch: "qa"})
+
+	//qa 未合入
+	assert.False(t, c.ResolveIssue(j))
+	assert.Equal(t, 0, transitions)
+
+	j.Append(&model.JiraBranch{DevBranch: "feature", TargetBranch: "qa", Merged: true})
+	assert.True(t, c.ResolveIssue(j))
+	assert.True(t, j.Resolved)
+	assert.Equal(t, 1, transitions)
+
+	//已流转过的不会重复流转
+	assert.False(t, c.ResolveIssue(j))
+	assert.Equal(t, 1, transitions)
+
+	//重新patch后再次合入时重新流转
+	j.Append(&model.JiraBranch{DevBranch: "feature", TargetBranch: "qa"})
+	assert.False(t, j.Resolved)
+}
+
+func TestPatch_ResolveIssueBranchs(t *testing.T) {
+	p := &Patch{
+		PlanTgtBranchList: []string{"dev", "qa"},
+		BranchAlias:       map[string]string{"61": "v6.1"},
+		IssueBranch: &IssueBranch{
+			FixVersions:     map[string]string{"6.1.3": "61", "6.2.0": "v6.2"},
+			AffectsVersions: map[string]string{"6.1.2": "61", "6.0.9": "v6.0"},
+			Labels:          map[string]string{"need-qa": "qa"},
+		},
+		Issue: &tracker.Issue{
+			Key:             "VM-1",
+			FixVersions:     []string{"6.2.0", "6.1.3", "7.0.0"},
+			AffectsVersions: []string{"6.1.2", "6.0.9"},
+			Labels:          []string{"hotfix", "need-qa"},
+		},
+	}
+
+	sources := p.ResolveIssueBranchs()
+	assert.Equal(t, []*BranchSource{
+		{Branch: "v6.2", Source: "fixVersion 6.2.0"},
+		{Branch: "61", Source: "fixVersion 6.1.3"},
+		{Branch: "v6.0", Source: "affectsVersion 6.0.9"},
+		{Branch: "qa", Source: "label need-qa"},
+	}, sources)
+	assert.Equal(t, []string{"v6.2", "v6.1", "v6.0", "qa"}, p.GetTgtBranchs())
+	assert.Equal(t, []string{"dev", "qa", "v6.2", "v6.1", "v6.0"}, p.GetPlanTgtBranchList())
+
+	//指定了目标分支时不推导
+	p = &Patch{TgtBranchs: []string{"dev"}, IssueBranch: p.IssueBranch, Issue: p.Issue}
+	assert.Nil(t, p.ResolveIssueBranchs())
+	assert.Equal(t, []string{"dev"}, p.TgtBranchs)
+	assert.Nil(t, p.PlanTgtBranchList)
+}
+
+func TestConfig_Tracker(t *testing.T) {
+	c := &Config{
+		Patch:         &Patch{CurrentProject: "work"},
+		Repo:          map[string]*Repo{"work": {Url: "https://gitlab.example.com/group/work.git"}},
+		GitLabConfigs: []*GitLabConfig{{BaseUrl: "https://gitlab.example.com", Token: "token"}},
+		GitLabIssue:   &tracker.GitLabIssueConfig{BaseUrl: "https://gitlab.example.com"},
+	}
+
+	//未配置Jira时只用于提取key
+	assert.Len(t, c.Trackers(nil), 2)
+	assert.Nil(t, c.Tracker(nil, "VM-1"))
+	assert.Nil(t, c.Tracker(nil, "fix"))
+
+	//token和项目使用gitLab_configs和当前项目的配置
+	g := c.Tracker(nil, "#12")
+	assert.NotNil(t, g)
+	assert.Equal(t, "gitlab", g.Name())
+	assert.Equal(t, tracker.NewGitLabIssues(&tracker.GitLabIssueConfig{BaseUrl: "https://gitlab.example.com", Token: "token", Project: "group/work"}), g)
+
+	//push其他项目时使用该项目的仓库
+	g = c.Tracker(&Repo{Url: "https://gitlab.example.com/group/other.git"}, "#12")
+	assert.Equal(t, tracker.NewGitLabIssues(&tracker.GitLabIssueConfig{BaseUrl: "https://gitlab.example.com", Token: "token", Project: "group/other"}), g)
 }
@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+const (
+	ForgeGitLab = "gitlab"
+	ForgeGitHub = "github"
+	ForgeGitea  = "gitea"
+)
+
+// MR状态，各平台的状态统一转换为以下值
+const (
+	MrStateOpened = "opened"
+	MrStateMerged = "merged"
+	MrStateClosed = "closed"
+)
+
+var (
+	ErrForgeNotSupported = errors.New("当前代码托管平台不支持该操作")
+)
+
+// MergeRequest 代码托管平台上的MR(GitHub上称为PR)
+type MergeRequest struct {
+	Id           int
+	Title        string
+	WebUrl       string
+	State        string //opened,merged,closed
+	SourceBranch string
+	TargetBranch string
+}
+
+// Forge 代码托管平台，屏蔽GitLab/GitHub等平台的接口差异
+type Forge interface {
+	// CreateMergeRequest 创建MR
+	CreateMergeRequest(title, src, target string) (*MergeRequest, error)
+	// ListMergeRequests 查询MR，参数为空时不作为过滤条件
+	ListMergeRequests(state, src, target string) ([]*MergeRequest, error)
+	// GetMergeRequest 获取MR详情
+	GetMergeRequest(id int) (*MergeRequest, error)
+	// AcceptMergeRequest 合并MR，whenPipelineSucceeds为true时等待流水线成功后再合并
+	AcceptMergeRequest(id int, whenPipelineSucceeds bool) error
+	// NewMergeRequestUrl 手动创建MR的页面地址
+	NewMergeRequestUrl(src, target string) string
+	// ListProtectedBranches 平台上受保护的分支，可以是通配符，如 release/*
+	ListProtectedBranches() ([]string, error)
+}
+
+// NewForge 根据配置的平台类型创建Forge，未配置时返回nil
+func NewForge(c *GitLabConfig, repoUrl string) Forge {
+	if c == nil {
+		return nil
+	}
+
+	switch c.Type {
+	case ForgeGitHub:
+		return newGitHubForge(c, repoUrl)
+	case ForgeGitea:
+		return newGiteaForge(c, repoUrl)
+	default:
+		return newGitLabForge(c, repoUrl)
+	}
+}
+
+// projectPath 从仓库地址中解析出项目路径，如 https://github.com/goeoeo/gitx => goeoeo/gitx
+func projectPath(baseUrl, repoUrl string) string {
+	p := strings.Replace(repoUrl, baseUrl, "", 1)
+	if p == repoUrl {
+		if u, err := url.Parse(repoUrl); err == nil {
+			p = u.Path
+		}
+	}
+	p = strings.Trim(p, "/")
+	return strings.TrimSuffix(p, ".git")
+}
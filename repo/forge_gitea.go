@@ -0,0 +1,180 @@
+package repo
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/goeoeo/gitx/util"
+)
+
+// giteaPageLimit Gitea单页最大返回数量
+const giteaPageLimit = 50
+
+// giteaForge Gitea/Forgejo Pull Request
+type giteaForge struct {
+	rest    *restClient
+	repoUrl string
+	owner   string
+	repo    string
+}
+
+type giteaPull struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	HtmlUrl string `json:"html_url"`
+	State   string `json:"state"`
+	Merged  bool   `json:"merged"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+func newGiteaForge(c *GitLabConfig, repoUrl string) *giteaForge {
+	apiUrl := c.ApiUrl
+	if apiUrl == "" {
+		apiUrl = strings.TrimRight(c.BaseUrl, "/") + "/api/v1"
+	}
+
+	f := &giteaForge{
+		rest: &restClient{
+			baseUrl: strings.TrimRight(apiUrl, "/"),
+			header: map[string]string{
+				"Accept":        "application/json",
+				"Authorization": "token " + c.Token,
+			},
+		},
+		repoUrl: repoUrl,
+	}
+
+	p := strings.SplitN(projectPath(c.BaseUrl, repoUrl), "/", 2)
+	f.owner = p[0]
+	if len(p) > 1 {
+		f.repo = p[1]
+	}
+	return f
+}
+
+func (f *giteaForge) CreateMergeRequest(title, src, target string) (mr *MergeRequest, err error) {
+	var pull giteaPull
+	body := map[string]string{
+		"title": title,
+		"body":  title,
+		"head":  src,
+		"base":  target,
+	}
+	if err = f.rest.do(http.MethodPost, f.path("pulls"), body, &pull); err != nil {
+		return
+	}
+
+	return pull.convert(), nil
+}
+
+func (f *giteaForge) ListMergeRequests(state, src, target string) (mrs []*MergeRequest, err error) {
+	q := url.Values{}
+	q.Set("limit", fmt.Sprint(giteaPageLimit))
+	switch state {
+	case MrStateOpened:
+		q.Set("state", "open")
+	case "":
+		q.Set("state", "all")
+	default:
+		q.Set("state", "closed")
+	}
+
+	//Gitea不支持按分支过滤，分页拉取后在本地过滤
+	for page := 1; ; page++ {
+		var pulls []*giteaPull
+		q.Set("page", fmt.Sprint(page))
+		if err = f.rest.do(http.MethodGet, f.path("pulls")+"?"+q.Encode(), nil, &pulls); err != nil {
+			return
+		}
+
+		for _, v := range pulls {
+			mr := v.convert()
+			if state != "" && mr.State != state {
+				continue
+			}
+			if src != "" && mr.SourceBranch != src {
+				continue
+			}
+			if target != "" && mr.TargetBranch != target {
+				continue
+			}
+			mrs = append(mrs, mr)
+		}
+
+		if len(pulls) < giteaPageLimit {
+			break
+		}
+	}
+	return
+}
+
+func (f *giteaForge) GetMergeRequest(id int) (mr *MergeRequest, err error) {
+	var pull giteaPull
+	if err = f.rest.do(http.MethodGet, f.path(fmt.Sprintf("pulls/%d", id)), nil, &pull); err != nil {
+		return
+	}
+
+	return pull.convert(), nil
+}
+
+func (f *giteaForge) AcceptMergeRequest(id int, whenPipelineSucceeds bool) (err error) {
+	body := map[string]any{
+		"Do":                        "squash",
+		"delete_branch_after_merge": true,
+	}
+	if whenPipelineSucceeds {
+		body["merge_when_checks_succeed"] = true
+	}
+
+	return f.rest.do(http.MethodPost, f.path(fmt.Sprintf("pulls/%d/merge", id)), body, nil)
+}
+
+func (f *giteaForge) ListProtectedBranches() (names []string, err error) {
+	var rules []*struct {
+		RuleName   string `json:"rule_name"` //可以是通配符
+		BranchName string `json:"branch_name"`
+	}
+
+	if err = f.rest.do(http.MethodGet, f.path("branch_protections"), nil, &rules); err != nil {
+		return
+	}
+
+	for _, v := range rules {
+		names = append(names, util.Default(v.RuleName, v.BranchName))
+	}
+	return
+}
+
+func (f *giteaForge) NewMergeRequestUrl(src, target string) string {
+	return fmt.Sprintf("%s/compare/%s...%s", strings.TrimRight(f.repoUrl, "/"), target, src)
+}
+
+func (f *giteaForge) path(p string) string {
+	return fmt.Sprintf("/repos/%s/%s/%s", f.owner, f.repo, p)
+}
+
+func (p *giteaPull) convert() *MergeRequest {
+	state := MrStateOpened
+	if p.State == "closed" {
+		state = MrStateClosed
+		if p.Merged {
+			state = MrStateMerged
+		}
+	}
+
+	return &MergeRequest{
+		Id:           p.Number,
+		Title:        p.Title,
+		WebUrl:       p.HtmlUrl,
+		State:        state,
+		SourceBranch: p.Head.Ref,
+		TargetBranch: p.Base.Ref,
+	}
+}
@@ -0,0 +1,264 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const gitHubApiUrl = "https://api.github.com"
+
+// gitHubPageLimit GitHub单页最大返回数量
+const gitHubPageLimit = 100
+
+// gitHubForge GitHub Pull Request
+type gitHubForge struct {
+	rest    *restClient
+	repoUrl string
+	owner   string
+	repo    string
+}
+
+type gitHubPull struct {
+	Number   int        `json:"number"`
+	Title    string     `json:"title"`
+	HtmlUrl  string     `json:"html_url"`
+	State    string     `json:"state"`
+	MergedAt *time.Time `json:"merged_at"`
+	Head     struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+func newGitHubForge(c *GitLabConfig, repoUrl string) *gitHubForge {
+	apiUrl := c.ApiUrl
+	if apiUrl == "" {
+		apiUrl = gitHubApiUrl
+		//GitHub Enterprise
+		if !strings.Contains(c.BaseUrl, "github.com") {
+			apiUrl = strings.TrimRight(c.BaseUrl, "/") + "/api/v3"
+		}
+	}
+
+	f := &gitHubForge{
+		rest: &restClient{
+			baseUrl: strings.TrimRight(apiUrl, "/"),
+			header: map[string]string{
+				"Accept":        "application/vnd.github+json",
+				"Authorization": "Bearer " + c.Token,
+			},
+		},
+		repoUrl: repoUrl,
+	}
+
+	p := strings.SplitN(projectPath(c.BaseUrl, repoUrl), "/", 2)
+	f.owner = p[0]
+	if len(p) > 1 {
+		f.repo = p[1]
+	}
+	return f
+}
+
+func (f *gitHubForge) CreateMergeRequest(title, src, target string) (mr *MergeRequest, err error) {
+	var pull gitHubPull
+	body := map[string]string{
+		"title": title,
+		"body":  title,
+		"head":  src,
+		"base":  target,
+	}
+	if err = f.rest.do(http.MethodPost, f.path("pulls"), body, &pull); err != nil {
+		return
+	}
+
+	return pull.convert(), nil
+}
+
+func (f *gitHubForge) ListMergeRequests(state, src, target string) (mrs []*MergeRequest, err error) {
+	q := url.Values{}
+	q.Set("per_page", fmt.Sprint(gitHubPageLimit))
+	switch state {
+	case MrStateOpened:
+		q.Set("state", "open")
+	case "":
+		q.Set("state", "all")
+	default:
+		//merged和closed在GitHub上都是closed，下面再过滤
+		q.Set("state", "closed")
+	}
+	if src != "" {
+		q.Set("head", f.owner+":"+src)
+	}
+	if target != "" {
+		q.Set("base", target)
+	}
+
+	for page := 1; ; page++ {
+		var pulls []*gitHubPull
+		q.Set("page", fmt.Sprint(page))
+		if err = f.rest.do(http.MethodGet, f.path("pulls")+"?"+q.Encode(), nil, &pulls); err != nil {
+			return
+		}
+
+		for _, v := range pulls {
+			mr := v.convert()
+			if state != "" && mr.State != state {
+				continue
+			}
+			mrs = append(mrs, mr)
+		}
+
+		if len(pulls) < gitHubPageLimit {
+			break
+		}
+	}
+	return
+}
+
+func (f *gitHubForge) GetMergeRequest(id int) (mr *MergeRequest, err error) {
+	var pull gitHubPull
+	if err = f.rest.do(http.MethodGet, f.path(fmt.Sprintf("pulls/%d", id)), nil, &pull); err != nil {
+		return
+	}
+
+	return pull.convert(), nil
+}
+
+func (f *gitHubForge) AcceptMergeRequest(id int, whenPipelineSucceeds bool) (err error) {
+	var mr *MergeRequest
+
+	//GitHub的自动合并只能通过GraphQL开启，暂不支持
+	if whenPipelineSucceeds {
+		return ErrForgeNotSupported
+	}
+
+	if mr, err = f.GetMergeRequest(id); err != nil {
+		return
+	}
+
+	body := map[string]string{"merge_method": "squash"}
+	if err = f.rest.do(http.MethodPut, f.path(fmt.Sprintf("pulls/%d/merge", id)), body, nil); err != nil {
+		return
+	}
+
+	//与GitLab的RemoveSourceBranch保持一致，合并后删除源分支
+	_ = f.rest.do(http.MethodDelete, f.path("git/refs/heads/"+mr.SourceBranch), nil, nil)
+	return
+}
+
+func (f *gitHubForge) ListProtectedBranches() (names []string, err error) {
+	q := url.Values{}
+	q.Set("protected", "true")
+	q.Set("per_page", fmt.Sprint(gitHubPageLimit))
+
+	for page := 1; ; page++ {
+		var branchs []*struct {
+			Name string `json:"name"`
+		}
+		q.Set("page", fmt.Sprint(page))
+		if err = f.rest.do(http.MethodGet, f.path("branches")+"?"+q.Encode(), nil, &branchs); err != nil {
+			return
+		}
+
+		for _, v := range branchs {
+			names = append(names, v.Name)
+		}
+
+		if len(branchs) < gitHubPageLimit {
+			break
+		}
+	}
+	return
+}
+
+func (f *gitHubForge) NewMergeRequestUrl(src, target string) string {
+	return fmt.Sprintf("%s/compare/%s...%s?expand=1", strings.TrimRight(f.repoUrl, "/"), target, src)
+}
+
+func (f *gitHubForge) path(p string) string {
+	return fmt.Sprintf("/repos/%s/%s/%s", f.owner, f.repo, p)
+}
+
+func (p *gitHubPull) convert() *MergeRequest {
+	state := MrStateOpened
+	if p.State == "closed" {
+		state = MrStateClosed
+		if p.MergedAt != nil {
+			state = MrStateMerged
+		}
+	}
+
+	return &MergeRequest{
+		Id:           p.Number,
+		Title:        p.Title,
+		WebUrl:       p.HtmlUrl,
+		State:        state,
+		SourceBranch: p.Head.Ref,
+		TargetBranch: p.Base.Ref,
+	}
+}
+
+// restClient 简单的json rest客户端
+type restClient struct {
+	baseUrl string
+	header  map[string]string
+	client  *http.Client
+}
+
+func (c *restClient) do(method, path string, in, out any) (err error) {
+	var (
+		body io.Reader
+		req  *http.Request
+		resp *http.Response
+		b    []byte
+	)
+
+	if in != nil {
+		if b, err = json.Marshal(in); err != nil {
+			return
+		}
+		body = bytes.NewReader(b)
+	}
+
+	if req, err = http.NewRequest(method, c.baseUrl+path, body); err != nil {
+		return
+	}
+	for k, v := range c.header {
+		req.Header.Set(k, v)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := c.client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	if resp, err = client.Do(req); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if b, err = io.ReadAll(resp.Body); err != nil {
+		return
+	}
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+
+	if out == nil || len(b) == 0 {
+		return
+	}
+
+	return json.Unmarshal(b, out)
+}
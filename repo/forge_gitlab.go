@@ -0,0 +1,176 @@
+package repo
+
+import (
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// gitLabForge GitLab MR
+type gitLabForge struct {
+	config  *GitLabConfig
+	repoUrl string
+	pid     string
+}
+
+func newGitLabForge(c *GitLabConfig, repoUrl string) *gitLabForge {
+	return &gitLabForge{
+		config:  c,
+		repoUrl: repoUrl,
+		pid:     projectPath(c.BaseUrl, repoUrl),
+	}
+}
+
+func (f *gitLabForge) client() (*gitlab.Client, error) {
+	return gitlab.NewClient(f.config.Token, gitlab.WithBaseURL(f.config.BaseUrl))
+}
+
+func (f *gitLabForge) CreateMergeRequest(title, src, target string) (mr *MergeRequest, err error) {
+	var (
+		gitClient *gitlab.Client
+		res       *gitlab.MergeRequest
+	)
+
+	if gitClient, err = f.client(); err != nil {
+		return
+	}
+
+	if res, _, err = gitClient.MergeRequests.CreateMergeRequest(f.pid, &gitlab.CreateMergeRequestOptions{
+		Title:              stringPtr(title),
+		Description:        stringPtr(title),
+		SourceBranch:       stringPtr(src),
+		TargetBranch:       stringPtr(target),
+		RemoveSourceBranch: boolPtr(true),
+		Squash:             boolPtr(true),
+	}); err != nil {
+		return
+	}
+
+	return f.convert(res), nil
+}
+
+func (f *gitLabForge) ListMergeRequests(state, src, target string) (mrs []*MergeRequest, err error) {
+	var (
+		gitClient *gitlab.Client
+		resSet    []*gitlab.MergeRequest
+	)
+
+	if gitClient, err = f.client(); err != nil {
+		return
+	}
+
+	opt := &gitlab.ListProjectMergeRequestsOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	if state != "" {
+		opt.State = stringPtr(state)
+	}
+	if src != "" {
+		opt.SourceBranch = stringPtr(src)
+	}
+	if target != "" {
+		opt.TargetBranch = stringPtr(target)
+	}
+
+	for {
+		var resp *gitlab.Response
+		if resSet, resp, err = gitClient.MergeRequests.ListProjectMergeRequests(f.pid, opt); err != nil {
+			return
+		}
+
+		for _, v := range resSet {
+			mrs = append(mrs, f.convert(v))
+		}
+
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return
+}
+
+func (f *gitLabForge) GetMergeRequest(id int) (mr *MergeRequest, err error) {
+	var (
+		gitClient *gitlab.Client
+		res       *gitlab.MergeRequest
+	)
+
+	if gitClient, err = f.client(); err != nil {
+		return
+	}
+
+	if res, _, err = gitClient.MergeRequests.GetMergeRequest(f.pid, id, nil); err != nil {
+		return
+	}
+
+	return f.convert(res), nil
+}
+
+func (f *gitLabForge) AcceptMergeRequest(id int, whenPipelineSucceeds bool) (err error) {
+	var (
+		gitClient *gitlab.Client
+		opt       *gitlab.AcceptMergeRequestOptions
+	)
+
+	if gitClient, err = f.client(); err != nil {
+		return
+	}
+
+	if whenPipelineSucceeds {
+		opt = &gitlab.AcceptMergeRequestOptions{MergeWhenPipelineSucceeds: boolPtr(true)}
+	}
+
+	_, _, err = gitClient.MergeRequests.AcceptMergeRequest(f.pid, id, opt)
+	return
+}
+
+func (f *gitLabForge) ListProtectedBranches() (names []string, err error) {
+	var (
+		gitClient *gitlab.Client
+		resSet    []*gitlab.ProtectedBranch
+		resp      *gitlab.Response
+	)
+
+	if gitClient, err = f.client(); err != nil {
+		return
+	}
+
+	opt := &gitlab.ListProtectedBranchesOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	for {
+		if resSet, resp, err = gitClient.ProtectedBranches.ListProtectedBranches(f.pid, opt); err != nil {
+			return
+		}
+
+		for _, v := range resSet {
+			names = append(names, v.Name)
+		}
+
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return
+}
+
+func (f *gitLabForge) NewMergeRequestUrl(src, target string) string {
+	return gitLabNewMergeRequestUrl(f.repoUrl, src, target)
+}
+
+func (f *gitLabForge) convert(mr *gitlab.MergeRequest) *MergeRequest {
+	return &MergeRequest{
+		Id:           mr.IID,
+		Title:        mr.Title,
+		WebUrl:       mr.WebURL,
+		State:        mr.State,
+		SourceBranch: mr.SourceBranch,
+		TargetBranch: mr.TargetBranch,
+	}
+}
+
+// gitLabNewMergeRequestUrl GitLab手动创建MR的页面地址
+func gitLabNewMergeRequestUrl(repoUrl, src, target string) string {
+	// merge_requests/new?merge_request%5Bsource_branch%5D=VM-2074_VG_Migrate_iaas&merge_request%5Btarget_branch%5D=staging_iaas
+	srcMerge := "merge_request%5Bsource_branch%5D=" + src
+	targetMerge := "merge_request%5Btarget_branch%5D=" + target
+	return strings.TrimRight(repoUrl, "/") + "/merge_requests/new?" + srcMerge + "&" + targetMerge
+}
@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectPath(t *testing.T) {
+	assert.Equal(t, "goeoeo/gitx", projectPath("https://github.com", "https://github.com/goeoeo/gitx"))
+	assert.Equal(t, "bi/pitrix-wh-daemon", projectPath("https://git.internal.yunify.com/", "https://git.internal.yunify.com/bi/pitrix-wh-daemon.git"))
+}
+
+func TestNewForge(t *testing.T) {
+	assert.Nil(t, NewForge(nil, "https://github.com/goeoeo/gitx"))
+
+	f := NewForge(&GitLabConfig{BaseUrl: "https://github.com", Type: ForgeGitHub}, "https://github.com/goeoeo/gitx")
+	gh, ok := f.(*gitHubForge)
+	assert.True(t, ok)
+	assert.Equal(t, gitHubApiUrl, gh.rest.baseUrl)
+	assert.Equal(t, "goeoeo", gh.owner)
+	assert.Equal(t, "gitx", gh.repo)
+
+	_, ok = NewForge(&GitLabConfig{BaseUrl: "https://git.internal.yunify.com"}, "https://git.internal.yunify.com/bi/x").(*gitLabForge)
+	assert.True(t, ok)
+}
+
+func TestGitHubForge(t *testing.T) {
+	var merged, deleted bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/goeoeo/gitx/pulls", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		if r.Method == http.MethodPost {
+			var body map[string]string
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "VM-1_x_dev", body["head"])
+			assert.Equal(t, "dev", body["base"])
+			_, _ = w.Write([]byte(`{"number":7,"title":"VM-1","html_url":"https://github.com/goeoeo/gitx/pull/7","state":"open","head":{"ref":"VM-1_x_dev"},"base":{"ref":"dev"}}`))
+			return
+		}
+
+		assert.Equal(t, "open", r.URL.Query().Get("state"))
+		assert.Equal(t, "goeoeo:VM-1_x_dev", r.URL.Query().Get("head"))
+		_, _ = w.Write([]byte(`[{"number":7,"state":"open","head":{"ref":"VM-1_x_dev"},"base":{"ref":"dev"}}]`))
+	})
+	mux.HandleFunc("/repos/goeoeo/gitx/pulls/7", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"number":7,"state":"closed","merged_at":"2024-01-01T00:00:00Z","head":{"ref":"VM-1_x_dev"},"base":{"ref":"dev"}}`))
+	})
+	mux.HandleFunc("/repos/goeoeo/gitx/pulls/7/merge", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		merged = true
+		_, _ = w.Write([]byte(`{"merged":true}`))
+	})
+	mux.HandleFunc("/repos/goeoeo/gitx/git/refs/heads/VM-1_x_dev", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	f := NewForge(&GitLabConfig{BaseUrl: "https://github.com", Token: "token", Type: ForgeGitHub, ApiUrl: server.URL}, "https://github.com/goeoeo/gitx")
+
+	mr, err := f.CreateMergeRequest("VM-1", "VM-1_x_dev", "dev")
+	assert.Nil(t, err)
+	assert.Equal(t, 7, mr.Id)
+	assert.Equal(t, MrStateOpened, mr.State)
+	assert.Equal(t, "https://github.com/goeoeo/gitx/pull/7", mr.WebUrl)
+
+	mrs, err := f.ListMergeRequests(MrStateOpened, "VM-1_x_dev", "")
+	assert.Nil(t, err)
+	assert.Len(t, mrs, 1)
+
+	mr, err = f.GetMergeRequest(7)
+	assert.Nil(t, err)
+	assert.Equal(t, MrStateMerged, mr.State)
+
+	assert.ErrorIs(t, f.AcceptMergeRequest(7, true), ErrForgeNotSupported)
+	assert.Nil(t, f.AcceptMergeRequest(7, false))
+	assert.True(t, merged)
+	assert.True(t, deleted)
+}
+
+func TestGiteaForge(t *testing.T) {
+	var mergeBody map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/repos/mirror/gitx/pulls", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token token", r.Header.Get("Authorization"))
+		if r.Method == http.MethodPost {
+			_, _ = w.Write([]byte(`{"number":3,"title":"VM-1","html_url":"https://gitea.example.com/mirror/gitx/pulls/3","state":"open","head":{"ref":"VM-1_x_dev"},"base":{"ref":"dev"}}`))
+			return
+		}
+
+		assert.Equal(t, "open", r.URL.Query().Get("state"))
+		_, _ = w.Write([]byte(`[{"number":3,"state":"open","head":{"ref":"VM-1_x_dev"},"base":{"ref":"dev"}},{"number":4,"state":"open","head":{"ref":"VM-2_x_dev"},"base":{"ref":"dev"}}]`))
+	})
+	mux.HandleFunc("/api/v1/repos/mirror/gitx/pulls/3", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"number":3,"state":"closed","merged":true,"head":{"ref":"VM-1_x_dev"},"base":{"ref":"dev"}}`))
+	})
+	mux.HandleFunc("/api/v1/repos/mirror/gitx/pulls/3/merge", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&mergeBody))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := &Config{GiteaConfigs: []*GitLabConfig{{BaseUrl: server.URL, Token: "token"}}}
+	f := NewForge(c.GetGitLabConfig(server.URL+"/mirror/gitx"), server.URL+"/mirror/gitx")
+	assert.Equal(t, "", c.GiteaConfigs[0].Type)
+
+	mr, err := f.CreateMergeRequest("VM-1", "VM-1_x_dev", "dev")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, mr.Id)
+
+	mrs, err := f.ListMergeRequests(MrStateOpened, "VM-1_x_dev", "dev")
+	assert.Nil(t, err)
+	assert.Len(t, mrs, 1)
+	assert.Equal(t, 3, mrs[0].Id)
+
+	mr, err = f.GetMergeRequest(3)
+	assert.Nil(t, err)
+	assert.Equal(t, MrStateMerged, mr.State)
+
+	assert.Nil(t, f.AcceptMergeRequest(3, true))
+	assert.Equal(t, "squash", mergeBody["Do"])
+	assert.Equal(t, true, mergeBody["merge_when_checks_succeed"])
+
+	assert.Equal(t, server.URL+"/mirror/gitx/compare/dev...VM-1_x_dev", f.NewMergeRequestUrl("VM-1_x_dev", "dev"))
+}
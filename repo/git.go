@@ -12,8 +12,9 @@ import (
 	"time"
 
 	"github.com/goeoeo/gitx/model"
+	"github.com/goeoeo/gitx/tracker"
+	"github.com/goeoeo/gitx/util"
 	"github.com/sirupsen/logrus"
-	"github.com/xanzy/go-gitlab"
 )
 
 const (
@@ -25,7 +26,7 @@ const (
 type GitRepo struct {
 	Path          string // 本地路径
 	Url           string // https url
-	gitlabConfig  *GitLabConfig
+	forge         Forge  // 代码托管平台，未配置时为nil
 	currentBranch string //记录工作区操作之前的分支
 }
 
@@ -33,7 +34,7 @@ func NewGitRepo(path string, url string) *GitRepo {
 	return &GitRepo{
 		Path:          path,
 		Url:           url,
-		gitlabConfig:  GetConfig().GetGitLabConfig(url),
+		forge:         NewForge(GetConfig().GetGitLabConfig(url), url),
 		currentBranch: AutoBranch(path),
 	}
 }
@@ -204,7 +205,7 @@ func (g *GitRepo) RebaseAbort() error {
 }
 
 func (g *GitRepo) DelLocalBranch(branch string) error {
-	if len(branch) == 0 || branch == "master" {
+	if len(branch) == 0 {
 		logrus.Debugf("illegal parameter: branch: %s \n", branch)
 		return errors.New("illegal params: branch")
 	}
@@ -217,47 +218,61 @@ func (g *GitRepo) DelLocalBranch(branch string) error {
 }
 
 func (g *GitRepo) DelRemoteBranch(branch string) error {
-	// 不可删除远程的 master, qa, staging, staging_iaas, QCE_* 的分支。
-	if len(branch) == 0 || branch == "master" || branch == "qa" || branch == "staging" || branch == "staging_iaas" ||
-		strings.HasPrefix(branch, "QCE_") {
+	if len(branch) == 0 {
 		logrus.Debugf("illegal parameter: branch: %s \n", branch)
 		return errors.New("illegal params: branch")
 	}
+	// 不可删除受保护的分支，见 protected_branches
+	if err := g.checkProtected(branch); err != nil {
+		return err
+	}
 
 	// 删除远程分支前需要检查远程是否有该分支相关的mr没有合并
-	if g.gitlabConfig != nil && g.gitlabConfig.Token != "" {
-		gitClient, err := gitlab.NewClient(g.gitlabConfig.Token, gitlab.WithBaseURL(g.gitlabConfig.BaseUrl))
-		if err == nil {
-			// 查询以当前分支为源分支的未合并MR
-			openedMRs, _, err := gitClient.MergeRequests.ListProjectMergeRequests(g.getPid(), &gitlab.ListProjectMergeRequestsOptions{
-				State:        gitlab.String("opened"),
-				SourceBranch: gitlab.String(branch),
-			})
-			if err == nil && len(openedMRs) > 0 {
-				logrus.Warnf("跳过删除分支 %s，存在未合并的MR: %d 个\n", branch, len(openedMRs))
-				for _, mr := range openedMRs {
-					logrus.Warnf("MR标题: %s, URL: %s\n", mr.Title, mr.WebURL)
-				}
-				return fmt.Errorf("分支 %s 存在未合并的MR，无法删除", branch)
+	if g.forge != nil {
+		// 查询以当前分支为源分支的未合并MR
+		openedMRs, err := g.forge.ListMergeRequests(MrStateOpened, branch, "")
+		if err == nil && len(openedMRs) > 0 {
+			logrus.Warnf("跳过删除分支 %s，存在未合并的MR: %d 个\n", branch, len(openedMRs))
+			for _, mr := range openedMRs {
+				logrus.Warnf("MR标题: %s, URL: %s\n", mr.Title, mr.WebUrl)
 			}
+			return fmt.Errorf("分支 %s 存在未合并的MR，无法删除", branch)
 		}
 	}
 
 	cmdRet, err := ExecCmd(g.Path, "git", "push", "origin", "--delete", branch)
 	if err != nil {
 		logrus.Debugf("delete remote branch faild: out: %s, err: %s \n", cmdRet.Out, cmdRet.ErrStr)
-		return err
+		return fmt.Errorf("%v:%s", err, strings.TrimSpace(cmdRet.ErrStr))
 	}
 	return nil
 }
 
 func (g *GitRepo) GetCommitInfo(jiraId string) (cis []*model.CommitInfo, err error) {
+	return g.GetRefCommitInfo("", jiraId)
+}
+
+// GrepKey 按任务key过滤commit message的参数，key前后不能紧跟字母数字，
+// 避免 #12 匹配到 #123、group/x#12，VM-1 匹配到 VM-10
+func GrepKey(key string) []string {
+	left := `(^|[^0-9A-Za-z_])`
+	if strings.Contains(key, "#") {
+		left = `(^|[^0-9A-Za-z_/.-])`
+	}
+	return []string{"-E", "--grep=" + left + regexp.QuoteMeta(key) + `([^0-9A-Za-z_]|$)`}
+}
+
+// GetRefCommitInfo 从指定分支中获取jira相关的commit，ref为空时取当前分支
+func (g *GitRepo) GetRefCommitInfo(ref, jiraId string) (cis []*model.CommitInfo, err error) {
 	var (
 		commitLogs string
 	)
 
-	greps := fmt.Sprintf("--grep=%s", jiraId)
-	cmdRet, err := ExecCmd(g.Path, "git", "log", "--pretty=format:%H|%s|%cd", "--no-merges", greps)
+	args := append([]string{"log", "--pretty=format:%H|%s|%cd", "--no-merges"}, GrepKey(jiraId)...)
+	if ref != "" {
+		args = append(args, ref, "--")
+	}
+	cmdRet, err := ExecCmd(g.Path, "git", args...)
 	if err != nil {
 		return nil, err
 	}
@@ -271,21 +286,12 @@ func (g *GitRepo) GetCommitInfo(jiraId string) (cis []*model.CommitInfo, err err
 		if len(commitLine) == 0 {
 			continue
 		}
-		lineSplit := strings.SplitN(commitLine, "|", 3)
-		arr := strings.Split(lineSplit[2], " ")
-		arr = arr[0 : len(arr)-1]
-		timeObj, err := time.ParseInLocation(time.ANSIC, strings.Join(arr, " "), time.Local)
+		ci, err := parseCommitLine(commitLine)
 		if err != nil {
 			return nil, err
 		}
 
-		ci := &model.CommitInfo{
-			CommitId:   lineSplit[0],
-			Desc:       lineSplit[1],
-			CreateTime: timeObj,
-		}
-
-		if g.checkInRevertCommit(lineSplit[1], RevertCommitLogs) {
+		if g.checkInRevertCommit(ci.Desc, RevertCommitLogs) {
 			continue
 		}
 		cis = append(cis, ci)
@@ -294,6 +300,37 @@ func (g *GitRepo) GetCommitInfo(jiraId string) (cis []*model.CommitInfo, err err
 	return
 }
 
+// GetCommit 获取指定commit的信息，rev可以是commitID的前缀
+func (g *GitRepo) GetCommit(rev string) (ci *model.CommitInfo, err error) {
+	cmdRet, err := ExecCmd(g.Path, "git", "log", "-1", "--pretty=format:%H|%s|%cd", rev, "--")
+	if err != nil {
+		return nil, fmt.Errorf("commit不存在:%s", rev)
+	}
+
+	return parseCommitLine(strings.TrimSpace(cmdRet.Out))
+}
+
+// parseCommitLine 解析 %H|%s|%cd 格式的git log
+func parseCommitLine(commitLine string) (*model.CommitInfo, error) {
+	lineSplit := strings.SplitN(commitLine, "|", 3)
+	if len(lineSplit) != 3 {
+		return nil, fmt.Errorf("无法解析commit:%s", commitLine)
+	}
+
+	arr := strings.Split(lineSplit[2], " ")
+	arr = arr[0 : len(arr)-1]
+	timeObj, err := time.ParseInLocation(time.ANSIC, strings.Join(arr, " "), time.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.CommitInfo{
+		CommitId:   lineSplit[0],
+		Desc:       lineSplit[1],
+		CreateTime: timeObj,
+	}, nil
+}
+
 func (g *GitRepo) CherryPick(commit string) (skip bool, err error) {
 	if len(commit) == 0 {
 		logrus.Debugf("illegal parameter: commit: %s \n", commit)
@@ -321,6 +358,68 @@ func (g *GitRepo) CherryPickSkip() error {
 	return nil
 }
 
+// CherryPickContinue 冲突处理完成后继续，已手动提交时直接返回
+func (g *GitRepo) CherryPickContinue() error {
+	if _, err := ExecCmd(g.Path, "git", "rev-parse", "-q", "--verify", "CHERRY_PICK_HEAD"); err != nil {
+		return nil
+	}
+
+	cmdRet, err := ExecCmd(g.Path, "git", "-c", "core.editor=true", "cherry-pick", "--continue")
+	if err != nil {
+		logrus.Debugf("git cherry-pick --continue faild: out: %s, err: %s \n", cmdRet.Out, cmdRet.ErrStr)
+		return errors.New(strings.TrimSpace(cmdRet.ErrStr + cmdRet.Out))
+	}
+	return nil
+}
+
+func (g *GitRepo) CherryPickAbort() error {
+	cmdRet, err := ExecCmd(g.Path, "git", "cherry-pick", "--abort")
+	if err != nil {
+		logrus.Debugf("git cherry-pick --abort faild: out: %s, err: %s \n", cmdRet.Out, cmdRet.ErrStr)
+		return err
+	}
+	return nil
+}
+
+// Worktree 返回指向工作树目录的GitRepo，与当前仓库共享对象和分支
+func (g *GitRepo) Worktree(path string) *GitRepo {
+	return &GitRepo{
+		Path:          path,
+		Url:           g.Url,
+		forge:         g.forge,
+		currentBranch: g.currentBranch,
+	}
+}
+
+// WorktreeAdd 在path下创建工作树，startPoint不为空时将branch重置到startPoint
+func (g *GitRepo) WorktreeAdd(path, branch, startPoint string) error {
+	args := []string{"worktree", "add", path, branch}
+	if startPoint != "" {
+		args = []string{"worktree", "add", "-B", branch, path, startPoint}
+	}
+
+	cmdRet, err := ExecCmd(g.Path, "git", args...)
+	if err != nil {
+		logrus.Debugf("git worktree add faild: out: %s, err: %s \n", cmdRet.Out, cmdRet.ErrStr)
+		return err
+	}
+	return nil
+}
+
+// WorktreeRemove 删除工作树，工作树中的分支会保留
+func (g *GitRepo) WorktreeRemove(path string) error {
+	cmdRet, err := ExecCmd(g.Path, "git", "worktree", "remove", "--force", path)
+	if err != nil {
+		logrus.Debugf("git worktree remove faild: out: %s, err: %s \n", cmdRet.Out, cmdRet.ErrStr)
+	}
+
+	// 目录被手动删除时需要prune清理残留的工作树信息
+	if _, pErr := ExecCmd(g.Path, "git", "worktree", "prune"); pErr != nil {
+		return pErr
+	}
+	return err
+}
+
 func (g *GitRepo) Push(localBranch, srcBranch string) error {
 	// git push --set-upstream origin VM-2074_VG_Migrate_qa -o 'src_branch=qa'
 	cmdRet, err := ExecCmd(g.Path, "git", "push", "-f", "--set-upstream", "origin", localBranch,
@@ -355,12 +454,81 @@ func (g *GitRepo) Fetch() error {
 	return nil
 }
 
+// FetchBranch 拉取远端分支，更新 origin/branch
+func (g *GitRepo) FetchBranch(branch string) error {
+	cmdRet, err := ExecCmd(g.Path, "git", "fetch", "origin", branch)
+	if err != nil {
+		logrus.Debugf("git fetch %s faild: out: %s, err: %s \n", branch, cmdRet.Out, cmdRet.ErrStr)
+		return err
+	}
+	return nil
+}
+
+// RefExists ref是否存在
+func (g *GitRepo) RefExists(ref string) bool {
+	_, err := ExecCmdEnv(g.Path, nil, "", "git", "rev-parse", "--verify", "--quiet", ref)
+	return err == nil
+}
+
+// IsAncestor commit是否已包含在ref中
+func (g *GitRepo) IsAncestor(commit, ref string) bool {
+	_, err := ExecCmdEnv(g.Path, nil, "", "git", "merge-base", "--is-ancestor", commit, ref)
+	return err == nil
+}
+
+// GetRemoteBranchsContains 包含commit的远程分支
+func (g *GitRepo) GetRemoteBranchsContains(commit string) (branchs []string, err error) {
+	cmdRet, err := ExecCmdEnv(g.Path, nil, "", "git", "branch", "-r", "--contains", commit, "--format=%(refname:short)")
+	if err != nil {
+		return nil, fmt.Errorf("git branch --contains %s 失败:%s", commit, cmdRet.ErrStr)
+	}
+
+	for _, line := range strings.Split(cmdRet.Out, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "origin/") || line == "origin/HEAD" {
+			continue
+		}
+		branchs = append(branchs, strings.TrimPrefix(line, "origin/"))
+	}
+	return
+}
+
+// PatchIds 计算git log选出的commit的patch-id，返回 commitId => patchId
+func (g *GitRepo) PatchIds(args ...string) (ids map[string]string, err error) {
+	var (
+		cmdRet *CmdRet
+	)
+
+	ids = make(map[string]string)
+	args = append([]string{"log", "-p", "--no-merges", "--no-color", "--no-ext-diff"}, args...)
+	if cmdRet, err = ExecCmdEnv(g.Path, nil, "", "git", args...); err != nil {
+		return nil, fmt.Errorf("git log 失败:%s", cmdRet.ErrStr)
+	}
+
+	if strings.TrimSpace(cmdRet.Out) == "" {
+		return
+	}
+
+	if cmdRet, err = ExecCmdEnv(g.Path, nil, cmdRet.Out, "git", "patch-id", "--stable"); err != nil {
+		return nil, fmt.Errorf("git patch-id 失败:%s", cmdRet.ErrStr)
+	}
+
+	for _, line := range strings.Split(cmdRet.Out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		ids[fields[1]] = fields[0]
+	}
+	return
+}
+
 func (g *GitRepo) NewMergeReq(srcBranch, targetBranch string) string {
-	// merge_requests/new?merge_request%5Bsource_branch%5D=VM-2074_VG_Migrate_iaas&merge_request%5Btarget_branch%5D=staging_iaas
-	srcMerge := "merge_request%5Bsource_branch%5D=" + srcBranch
-	targetMerge := "merge_request%5Btarget_branch%5D=" + targetBranch
-	mergeUrl := strings.TrimRight(g.Url, "/") + "/merge_requests/new?" + srcMerge + "&" + targetMerge
-	return mergeUrl
+	if g.forge != nil {
+		return g.forge.NewMergeRequestUrl(srcBranch, targetBranch)
+	}
+
+	return gitLabNewMergeRequestUrl(g.Url, srcBranch, targetBranch)
 }
 
 func (g *GitRepo) LsRemote() error {
@@ -405,12 +573,11 @@ func (g *GitRepo) checkInRevertCommit(c string, revert_commits []string) bool {
 	return false
 }
 
-// CreateMergeRequest 创建Mr
+// CreateMergeRequest 创建Mr，已存在未合并的Mr时直接返回
 func (g *GitRepo) CreateMergeRequest(title, src, target string) (mrInfo *model.MrInfo, err error) {
 	var (
-		gitClient *gitlab.Client
-		mr        *gitlab.MergeRequest
-		resSet    []*gitlab.MergeRequest
+		mr     *MergeRequest
+		resSet []*MergeRequest
 	)
 	defer func() {
 		if err != nil {
@@ -418,51 +585,37 @@ func (g *GitRepo) CreateMergeRequest(title, src, target string) (mrInfo *model.M
 		}
 	}()
 
-	if gitClient, err = gitlab.NewClient(g.gitlabConfig.Token, gitlab.WithBaseURL(g.gitlabConfig.BaseUrl)); err != nil {
-		return
+	if g.forge == nil {
+		return nil, fmt.Errorf("未配置代码托管平台:%s", g.Url)
 	}
 
 	//查询
-	if resSet, _, err = gitClient.MergeRequests.ListProjectMergeRequests(g.getPid(), &gitlab.ListProjectMergeRequestsOptions{
-		State:        stringPtr("opened"),
-		SourceBranch: stringPtr(src),
-		TargetBranch: stringPtr(target),
-	}); err != nil {
+	if resSet, err = g.forge.ListMergeRequests(MrStateOpened, src, target); err != nil {
 		return
 	}
 
 	if len(resSet) > 0 {
 		mrInfo = &model.MrInfo{
 			Title:  resSet[0].Title,
-			MrId:   resSet[0].IID,
-			WebUrl: resSet[0].WebURL,
+			MrId:   resSet[0].Id,
+			WebUrl: resSet[0].WebUrl,
 		}
 		return
 	}
 
-	if mr, _, err = gitClient.MergeRequests.CreateMergeRequest(g.getPid(), &gitlab.CreateMergeRequestOptions{
-		Title:              stringPtr(title),
-		Description:        stringPtr(title),
-		SourceBranch:       stringPtr(src),
-		TargetBranch:       stringPtr(target),
-		RemoveSourceBranch: boolPtr(true),
-		Squash:             boolPtr(true),
-	}); err != nil {
+	if mr, err = g.forge.CreateMergeRequest(title, src, target); err != nil {
 		return
 	}
 
 	mrInfo = &model.MrInfo{
 		Title:  title,
-		MrId:   mr.IID,
-		WebUrl: mr.WebURL,
+		MrId:   mr.Id,
+		WebUrl: mr.WebUrl,
 	}
 	return
 }
 
 func (g *GitRepo) AcceptMergeRequest(mrId int) (res string, err error) {
-	var (
-		gitClient *gitlab.Client
-	)
 	defer func() {
 		if err != nil {
 			logrus.Debugf("CreateMergeRequest err:%s", err)
@@ -471,19 +624,19 @@ func (g *GitRepo) AcceptMergeRequest(mrId int) (res string, err error) {
 		}
 	}()
 
-	if gitClient, err = gitlab.NewClient(g.gitlabConfig.Token, gitlab.WithBaseURL(g.gitlabConfig.BaseUrl)); err != nil {
-		return
+	if g.forge == nil {
+		return "", fmt.Errorf("未配置代码托管平台:%s", g.Url)
 	}
 
 	res = MergeResOk
-	if _, _, err = gitClient.MergeRequests.AcceptMergeRequest(g.getPid(), mrId, nil); err != nil {
+	if err = g.forge.AcceptMergeRequest(mrId, false); err != nil {
 		logrus.Debugf("直接合并失败，mrId:%d,错误原因:%v", mrId, err)
 
 		mergeSuccess := false
 		for i := 0; i < 3; i++ {
 			time.Sleep(5 * time.Second)
 			logrus.Debugf("正在第%d/3次尝试重新合并%d", i+1, mrId)
-			if _, _, err = gitClient.MergeRequests.AcceptMergeRequest(g.getPid(), mrId, nil); err == nil {
+			if err = g.forge.AcceptMergeRequest(mrId, false); err == nil {
 				mergeSuccess = true
 				break
 			} else {
@@ -498,12 +651,15 @@ func (g *GitRepo) AcceptMergeRequest(mrId int) (res string, err error) {
 			for i := 0; i < 3; i++ {
 				time.Sleep(5 * time.Second)
 				logrus.Debugf("正在第%d/3次尝试重新合并%d", i+1, mrId)
-				if _, _, err = gitClient.MergeRequests.AcceptMergeRequest(g.getPid(), mrId, &gitlab.AcceptMergeRequestOptions{MergeWhenPipelineSucceeds: boolPtr(true)}); err == nil {
+				if err = g.forge.AcceptMergeRequest(mrId, true); err == nil {
 					res = MergeResWaitPipeline
 					mergeSuccess = true
 					break
 				} else {
 					logrus.Debugf("正在第%d/3次尝试重新合并%d，错误：%v", i+1, mrId, err)
+					if errors.Is(err, ErrForgeNotSupported) {
+						break
+					}
 				}
 			}
 		}
@@ -516,20 +672,16 @@ func (g *GitRepo) AcceptMergeRequest(mrId int) (res string, err error) {
 	return
 }
 
-func (g *GitRepo) GetMergeRequest(mrId int) (mr *gitlab.MergeRequest, err error) {
-	var (
-		gitClient *gitlab.Client
-	)
-	if gitClient, err = gitlab.NewClient(g.gitlabConfig.Token, gitlab.WithBaseURL(g.gitlabConfig.BaseUrl)); err != nil {
-		return
+func (g *GitRepo) GetMergeRequest(mrId int) (mr *MergeRequest, err error) {
+	if g.forge == nil {
+		return nil, fmt.Errorf("未配置代码托管平台:%s", g.Url)
 	}
 
-	mr, _, err = gitClient.MergeRequests.GetMergeRequest(g.getPid(), mrId, nil)
-	return
+	return g.forge.GetMergeRequest(mrId)
 }
 
-// AutoJiraID 获取目录下的第一个git log 解析出JIRA-ID
-func AutoJiraID(dir string, jiraProjects []string, commitID string) (JiraID string, commitType, commitMsg string) {
+// AutoJiraID 获取目录下的第一个git log 通过任务跟踪系统解析出任务key
+func AutoJiraID(dir string, trackers []tracker.Tracker, commitID string) (JiraID string, commitType, commitMsg string) {
 	var (
 		err error
 	)
@@ -550,20 +702,8 @@ func AutoJiraID(dir string, jiraProjects []string, commitID string) (JiraID stri
 		return "", "", ""
 	}
 
-	//配置了jira项目前缀
-	for _, p := range jiraProjects {
-		re := regexp.MustCompile(fmt.Sprintf(`%s-\w+`, p))
-		// 查找匹配项
-		if match := re.FindString(lines); match != "" {
-			return match, model.CommitTypeJira, ""
-		}
-	}
-
-	//尝试用正则来找
-	re := regexp.MustCompile(`\b[A-Z]+-\d+\b`)
-	// 查找匹配项
-	if match := re.FindString(lines); match != "" {
-		return match, model.CommitTypeJira, ""
+	if key := tracker.FindKey(trackers, lines); key != "" {
+		return key, model.CommitTypeJira, ""
 	}
 
 	//无法匹配则以整个message作为jira
@@ -592,13 +732,6 @@ func AutoBranch(dir string) string {
 	return ""
 }
 
-func (g *GitRepo) getPid() string {
-	projectPath := strings.Replace(g.Url, g.gitlabConfig.BaseUrl, "", 1)
-	projectPath = strings.Trim(projectPath, "/")
-
-	return projectPath
-}
-
 func stringPtr(s string) *string {
 	return &s
 }
@@ -606,3 +739,13 @@ func stringPtr(s string) *string {
 func boolPtr(b bool) *bool {
 	return &b
 }
+
+// OriginUrl 仓库origin的https地址，与 repo.json 中记录的地址格式一致
+func OriginUrl(dir string) (string, error) {
+	cmdRet, err := ExecCmd(dir, "git", "remote", "get-url", "origin")
+	if err != nil {
+		return "", fmt.Errorf("获取origin地址失败:%s", strings.TrimSpace(cmdRet.ErrStr))
+	}
+	url := util.ConvertGitToHTTP(strings.TrimSpace(cmdRet.Out))
+	return strings.TrimSuffix(url, ".git"), nil
+}
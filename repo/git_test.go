@@ -153,3 +153,27 @@ func TestPath(t *testing.T) {
 	f := "/a/b/c"
 	fmt.Println(util.GetLastDir(f))
 }
+
+func TestGitRepo_GetRefCommitInfoGrepKey(t *testing.T) {
+	dir := newTestRepo(t)
+	gitCommit(t, dir, "b.txt", "b\n", "VM-1 add b")
+	gitCommit(t, dir, "c.txt", "c\n", "VM-10 add c")
+	gitCommit(t, dir, "d.txt", "d\n", "fix #12")
+	gitCommit(t, dir, "e.txt", "e\n", "fix #123")
+	gitCommit(t, dir, "f.txt", "f\n", "fix group/x#12")
+	gitCommit(t, dir, "g.txt", "g\n", "fix(VM-1): g")
+
+	g := &GitRepo{Path: dir}
+	desc := func(key string) (res []string) {
+		cis, err := g.GetRefCommitInfo("master", key)
+		assert.Nil(t, err)
+		for _, ci := range cis {
+			res = append(res, ci.Desc)
+		}
+		return
+	}
+
+	assert.Equal(t, []string{"fix(VM-1): g", "VM-1 add b"}, desc("VM-1"))
+	assert.Equal(t, []string{"fix #12"}, desc("#12"))
+	assert.Equal(t, []string{"fix group/x#12"}, desc("group/x#12"))
+}
@@ -0,0 +1,482 @@
+package repo
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/goeoeo/gitx/tracker"
+	"github.com/goeoeo/gitx/util"
+	"github.com/sirupsen/logrus"
+)
+
+// 导入结果
+const (
+	ImportOk       = "imported" //已导入
+	ImportExists   = "exists"   //本地已有push记录
+	ImportUnmapped = "unmapped" //无法解析
+)
+
+var tmpBranchVarRe = regexp.MustCompile(`\{(jiraID|jiraDesc|tgtBranch)\}`)
+
+type (
+	// TmpBranchParser 根据 tmp_branch_fmt 从临时分支名中解析出任务key和目标分支
+	TmpBranchParser struct {
+		format string
+		re     *regexp.Regexp            //目标分支未知时使用
+		cache  map[string]*regexp.Regexp //目标分支 => 正则
+	}
+
+	// ImportItem 从远程分支、MR中重建的一条记录
+	ImportItem struct {
+		Branch    string
+		JiraID    string
+		TgtBranch string
+		DevBranch string
+		Merged    bool
+		Commits   []*model.CommitInfo
+		MRs       []*MergeRequest //按Id递增
+		Result    string
+		Reason    string //无法导入的原因
+	}
+
+	// Importer 扫描项目的远程分支和MR，重建jira记录
+	Importer struct {
+		git      *GitRepo
+		parser   *TmpBranchParser
+		trackers []tracker.Tracker
+		remote   []string                     //远程分支
+		mrs      map[string][]*MergeRequest   //源分支 => MR
+		patchIds map[string]map[string]string //jiraID => 远程分支中commitId => patchId
+	}
+)
+
+func NewTmpBranchParser(format string) (p *TmpBranchParser, err error) {
+	if !strings.Contains(format, "{jiraID}") {
+		return nil, fmt.Errorf("tmp_branch_fmt 中缺少{jiraID}:%s", format)
+	}
+
+	p = &TmpBranchParser{
+		format: format,
+		cache:  map[string]*regexp.Regexp{},
+	}
+	if p.re, err = p.compile(""); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// compile 将临时分支的格式转换为正则，与 newBranchName 一致，每个变量只替换第一次出现的位置
+// tgtBranch 不为空时目标分支按原样匹配
+func (p *TmpBranchParser) compile(tgtBranch string) (*regexp.Regexp, error) {
+	var (
+		b    strings.Builder
+		last int
+		seen = map[string]bool{}
+	)
+
+	b.WriteString("^")
+	for _, loc := range tmpBranchVarRe.FindAllStringSubmatchIndex(p.format, -1) {
+		name := p.format[loc[2]:loc[3]]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		b.WriteString(regexp.QuoteMeta(p.format[last:loc[0]]))
+		switch name {
+		case "jiraID":
+			b.WriteString(`(?P<jiraID>[A-Za-z0-9._-]+?)`)
+		case "jiraDesc":
+			b.WriteString(`.*?`)
+		case "tgtBranch":
+			if tgtBranch == "" {
+				b.WriteString(`(?P<tgtBranch>.+)`)
+			} else {
+				b.WriteString(regexp.QuoteMeta(tgtBranch))
+			}
+		}
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(p.format[last:]))
+	b.WriteString("$")
+
+	return regexp.Compile(b.String())
+}
+
+// IsTmpBranch 分支名是否符合临时分支的格式
+func (p *TmpBranchParser) IsTmpBranch(name string) bool {
+	return p.re.MatchString(name)
+}
+
+// Parse 解析临时分支名，targets为可能的目标分支，优先匹配较长的分支名
+// 返回分支名中的任务key(经过 tracker.BranchKey 转换)，没有匹配的目标分支时tgtBranch为空
+func (p *TmpBranchParser) Parse(name string, targets []string) (branchKey, tgtBranch string) {
+	if branchKey = p.submatch(p.re, name); branchKey == "" {
+		return
+	}
+
+	if !strings.Contains(p.format, "{tgtBranch}") {
+		return
+	}
+
+	targets = append([]string{}, targets...)
+	sort.SliceStable(targets, func(i, j int) bool {
+		return len(targets[i]) > len(targets[j])
+	})
+
+	for _, t := range targets {
+		if t == "" || t == name {
+			continue
+		}
+
+		re, ok := p.cache[t]
+		if !ok {
+			var err error
+			if re, err = p.compile(t); err != nil {
+				continue
+			}
+			p.cache[t] = re
+		}
+
+		if key := p.submatch(re, name); key != "" {
+			return key, t
+		}
+	}
+	return
+}
+
+func (p *TmpBranchParser) submatch(re *regexp.Regexp, name string) string {
+	m := re.FindStringSubmatch(name)
+	if m == nil {
+		return ""
+	}
+	return m[re.SubexpIndex("jiraID")]
+}
+
+func NewImporter(r *Repo, c *Config) (im *Importer, err error) {
+	im = &Importer{
+		git:      NewGitRepo(r.Path, r.Url),
+		trackers: c.Trackers(r),
+		mrs:      map[string][]*MergeRequest{},
+		patchIds: map[string]map[string]string{},
+	}
+
+	if im.parser, err = NewTmpBranchParser(c.Patch.TmpBranchFmt); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Scan 扫描远程分支和MR中符合临时分支格式的分支，按分支名排序
+func (im *Importer) Scan() (items []*ImportItem, err error) {
+	var (
+		names []string
+	)
+
+	if ret, err := ExecCmd(im.git.Path, "git", "fetch", "--prune", "origin"); err != nil {
+		return nil, fmt.Errorf("拉取远程分支失败:%s", ret.ErrStr)
+	}
+
+	branchs, err := im.git.GetRemoteBranchs()
+	if err != nil {
+		return
+	}
+	for _, v := range branchs {
+		if !strings.Contains(v, "->") {
+			im.remote = append(im.remote, v)
+		}
+	}
+
+	if err = im.loadMergeRequests(); err != nil {
+		return
+	}
+
+	names = append(names, im.remote...)
+	for src := range im.mrs {
+		names = append(names, src)
+	}
+	names = util.Unique(names)
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !im.parser.IsTmpBranch(name) {
+			continue
+		}
+
+		var it *ImportItem
+		if it, err = im.scan(name); err != nil {
+			return
+		}
+		items = append(items, it)
+	}
+	return
+}
+
+// loadMergeRequests 拉取所有状态的MR，没有配置代码托管平台时只从远程分支导入
+func (im *Importer) loadMergeRequests() (err error) {
+	if im.git.forge == nil {
+		logrus.Warnf("未配置代码托管平台，只从远程分支导入:%s", im.git.Url)
+		return
+	}
+
+	im.mrs, err = im.git.mergeRequestsBySource()
+	return
+}
+
+// mergeRequestsBySource 拉取所有状态的MR，按源分支分组，同一源分支的MR按Id递增
+func (g *GitRepo) mergeRequestsBySource() (res map[string][]*MergeRequest, err error) {
+	var (
+		mrs []*MergeRequest
+	)
+
+	if mrs, err = g.forge.ListMergeRequests("", "", ""); err != nil {
+		return nil, fmt.Errorf("获取MR失败:%v", err)
+	}
+
+	sort.SliceStable(mrs, func(i, j int) bool {
+		return mrs[i].Id < mrs[j].Id
+	})
+
+	res = map[string][]*MergeRequest{}
+	for _, mr := range mrs {
+		res[mr.SourceBranch] = append(res[mr.SourceBranch], mr)
+	}
+	return
+}
+
+func (im *Importer) scan(name string) (it *ImportItem, err error) {
+	it = &ImportItem{Branch: name, MRs: im.mrs[name]}
+
+	//有MR时以MR的目标分支为准
+	targets := im.remote
+	if len(it.MRs) > 0 {
+		targets = []string{it.MRs[len(it.MRs)-1].TargetBranch}
+	}
+
+	branchKey, tgtBranch := im.parser.Parse(name, targets)
+	if tgtBranch == "" {
+		return it.unmapped("目标分支不存在"), nil
+	}
+	it.TgtBranch = tgtBranch
+
+	exists := util.ContainString(im.remote, name)
+	if exists {
+		if it.Commits, err = im.commits(it); err != nil {
+			return
+		}
+	}
+
+	if it.JiraID = im.resolveKey(branchKey, it); it.JiraID == "" {
+		return it.unmapped(fmt.Sprintf("无法识别任务:%s", branchKey)), nil
+	}
+
+	if len(it.MRs) > 0 {
+		it.Merged = it.MRs[len(it.MRs)-1].State == MrStateMerged
+	}
+	if !it.Merged && exists {
+		it.Merged = im.git.IsAncestor("origin/"+name, "origin/"+tgtBranch)
+	}
+
+	if exists {
+		if it.DevBranch, err = im.devBranch(it); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// commits 临时分支相对目标分支的commit，按时间正序
+func (im *Importer) commits(it *ImportItem) (cis []*model.CommitInfo, err error) {
+	var (
+		ret *CmdRet
+		ids map[string]string
+	)
+
+	rev := fmt.Sprintf("origin/%s..origin/%s", it.TgtBranch, it.Branch)
+	if ret, err = ExecCmd(im.git.Path, "git", "log", "--pretty=format:%H|%s|%cd", "--no-merges", "--reverse", rev, "--"); err != nil {
+		return nil, fmt.Errorf("读取分支commit失败:%s,%s", it.Branch, ret.ErrStr)
+	}
+
+	for _, line := range strings.Split(ret.Out, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		ci, err := parseCommitLine(line)
+		if err != nil {
+			return nil, err
+		}
+		cis = append(cis, ci)
+	}
+
+	if len(cis) == 0 {
+		return
+	}
+
+	if ids, err = im.git.PatchIds(rev); err != nil {
+		return
+	}
+	for _, ci := range cis {
+		ci.PatchId = ids[ci.CommitId]
+	}
+	return
+}
+
+// resolveKey 分支名中的key经过了 tracker.BranchKey 转换，从MR标题、commit中找到转换前的key
+func (im *Importer) resolveKey(branchKey string, it *ImportItem) string {
+	msgs := []string{branchKey}
+	for _, mr := range it.MRs {
+		msgs = append(msgs, mr.Title)
+	}
+	for _, ci := range it.Commits {
+		msgs = append(msgs, ci.Desc)
+	}
+
+	for _, msg := range msgs {
+		if key := tracker.FindKey(im.trackers, msg); key != "" && tracker.BranchKey(key) == branchKey {
+			return key
+		}
+	}
+	return ""
+}
+
+// devBranch 通过patch-id找到开发分支中对应的commit，包含该commit的分支视为开发分支，找不到时返回空
+// 找到时commit记录为开发分支中的commit，与push时的记录一致
+func (im *Importer) devBranch(it *ImportItem) (devBranch string, err error) {
+	var (
+		ids    map[string]string
+		ok     bool
+		branch []string
+	)
+
+	if len(it.Commits) == 0 {
+		return
+	}
+
+	if ids, ok = im.patchIds[it.JiraID]; !ok {
+		if ids, err = im.git.PatchIds(append(GrepKey(it.JiraID), "--remotes")...); err != nil {
+			return
+		}
+		im.patchIds[it.JiraID] = ids
+	}
+
+	own := map[string]bool{}
+	for _, ci := range it.Commits {
+		own[ci.CommitId] = true
+	}
+
+	for _, ci := range it.Commits {
+		for commit, patchId := range ids {
+			if own[commit] || ci.PatchId == "" || patchId != ci.PatchId {
+				continue
+			}
+
+			if branch, err = im.git.GetRemoteBranchsContains(commit); err != nil {
+				return
+			}
+			if b := im.pickDevBranch(branch, it); b != "" {
+				ci.CommitId = commit
+				devBranch = util.Default(devBranch, b)
+			}
+		}
+	}
+	return
+}
+
+// pickDevBranch 排除目标分支和同一任务的临时分支，优先选择不符合临时分支格式的分支
+func (im *Importer) pickDevBranch(branchs []string, it *ImportItem) (res string) {
+	for _, b := range branchs {
+		if b == it.TgtBranch || im.isTmpBranchOf(b, it.JiraID) {
+			continue
+		}
+		if !im.parser.IsTmpBranch(b) {
+			return b
+		}
+		res = util.Default(res, b)
+	}
+	return
+}
+
+// isTmpBranchOf 是否为同一个任务的临时分支
+func (im *Importer) isTmpBranchOf(name, jiraID string) bool {
+	branchKey, _ := im.parser.Parse(name, nil)
+	return branchKey == tracker.BranchKey(jiraID)
+}
+
+// Apply 将扫描结果写入jira记录，目标分支已有push记录时不覆盖
+func (im *Importer) Apply(jm *model.JiraMgr, project string, items []*ImportItem) {
+	for _, it := range items {
+		if it.Result == ImportUnmapped {
+			continue
+		}
+
+		j := jm.GetOrCreate(project, it.JiraID, model.CommitTypeJira, "")
+		if j.Import(it.jiraBranch()) {
+			it.Result = ImportOk
+		} else {
+			it.Result = ImportExists
+		}
+	}
+}
+
+func (it *ImportItem) unmapped(reason string) *ImportItem {
+	it.Result = ImportUnmapped
+	it.Reason = reason
+	return it
+}
+
+func (it *ImportItem) jiraBranch() *model.JiraBranch {
+	jb := &model.JiraBranch{
+		BranchName:   it.Branch,
+		DevBranch:    it.DevBranch,
+		TargetBranch: it.TgtBranch,
+		State:        model.JiraBranchPushed,
+		Commits:      it.Commits,
+		CreateTime:   time.Now(),
+		UpdateTime:   time.Now(),
+	}
+
+	if n := len(it.Commits); n > 0 {
+		jb.CreateTime = it.Commits[0].CreateTime
+		jb.UpdateTime = it.Commits[n-1].CreateTime
+		for _, ci := range it.Commits {
+			jb.Selection = append(jb.Selection, ci.CommitId)
+		}
+	}
+
+	if it.Merged {
+		jb.SetMerged()
+	}
+
+	for _, mr := range it.MRs {
+		jb.MergeRequests = append(jb.MergeRequests, &model.MrInfo{
+			Title:  mr.Title,
+			MrId:   mr.Id,
+			WebUrl: mr.WebUrl,
+		})
+	}
+	return jb
+}
+
+// MR 最近一次MR的地址
+func (it *ImportItem) MR() string {
+	if len(it.MRs) == 0 {
+		return ""
+	}
+	return it.MRs[len(it.MRs)-1].WebUrl
+}
+
+// Status 导入结果的描述
+func (it *ImportItem) Status() string {
+	switch it.Result {
+	case ImportOk:
+		return "已导入"
+	case ImportExists:
+		return "已存在"
+	default:
+		return "无法导入:" + it.Reason
+	}
+}
@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTmpBranchParser_Parse(t *testing.T) {
+	p, err := NewTmpBranchParser("{jiraID}_{jiraDesc}_{tgtBranch}")
+	assert.Nil(t, err)
+
+	key, tgt := p.Parse("VM-1_fix_login_release_1.0", []string{"dev", "release_1.0", "1.0"})
+	assert.Equal(t, "VM-1", key)
+	assert.Equal(t, "release_1.0", tgt)
+
+	key, tgt = p.Parse("VM-1_fix_qa", []string{"dev"})
+	assert.Equal(t, "VM-1", key)
+	assert.Equal(t, "", tgt)
+
+	assert.False(t, p.IsTmpBranch("dev"))
+
+	p, err = NewTmpBranchParser("{tgtBranch}/{jiraID}-{jiraDesc}")
+	assert.Nil(t, err)
+	key, tgt = p.Parse("release/1.0/group-proj-12-fix", []string{"release/1.0", "release"})
+	assert.Equal(t, "group", key)
+	assert.Equal(t, "release/1.0", tgt)
+
+	_, err = NewTmpBranchParser("{tgtBranch}_{jiraDesc}")
+	assert.NotNil(t, err)
+}
+
+func TestImporter(t *testing.T) {
+	dir := newTestRepo(t)
+	cfg.Repo["work"] = &Repo{Name: "work", Path: dir}
+	gitRun(t, dir, "checkout", "-b", "dev", "origin/dev")
+	gitCommit(t, dir, "d.txt", "d\n", "dev")
+	gitRun(t, dir, "push", "origin", "dev")
+
+	//开发分支
+	gitRun(t, dir, "checkout", "-b", "feature", "master")
+	gitCommit(t, dir, "b.txt", "b\n", "VM-1 add b")
+	gitCommit(t, dir, "c.txt", "c\n", "VM-2 add c")
+	gitRun(t, dir, "push", "origin", "feature")
+
+	//未合并的临时分支
+	gitRun(t, dir, "checkout", "-b", "VM-1_x_dev", "origin/dev")
+	gitRun(t, dir, "cherry-pick", "feature~1")
+	gitRun(t, dir, "push", "origin", "VM-1_x_dev")
+
+	//已合并的临时分支
+	gitRun(t, dir, "checkout", "-b", "VM-2_y_dev", "origin/dev")
+	gitRun(t, dir, "cherry-pick", "feature")
+	gitRun(t, dir, "push", "origin", "VM-2_y_dev", "VM-2_y_dev:dev")
+
+	//目标分支不存在
+	gitRun(t, dir, "push", "origin", "feature:VM-3_z_qa")
+	gitRun(t, dir, "checkout", "feature")
+
+	im, err := NewImporter(cfg.Repo["work"], cfg)
+	assert.Nil(t, err)
+	items, err := im.Scan()
+	assert.Nil(t, err)
+	assert.Len(t, items, 3)
+
+	assert.Equal(t, "VM-1", items[0].JiraID)
+	assert.Equal(t, "dev", items[0].TgtBranch)
+	assert.Equal(t, "feature", items[0].DevBranch)
+	assert.False(t, items[0].Merged)
+	assert.Len(t, items[0].Commits, 1)
+	assert.Equal(t, gitRun(t, dir, "rev-parse", "feature~1"), items[0].Commits[0].CommitId)
+
+	assert.Equal(t, "VM-2", items[1].JiraID)
+	assert.True(t, items[1].Merged)
+
+	assert.Equal(t, ImportUnmapped, items[2].Result)
+	assert.Equal(t, "目标分支不存在", items[2].Reason)
+
+	t.Setenv("HOME", t.TempDir())
+	jm, err := model.NewJiraMgr()
+	assert.Nil(t, err)
+	jm.GetOrCreate("work", "VM-2", model.CommitTypeJira, "").Append(&model.JiraBranch{BranchName: "VM-2_y_dev", DevBranch: "feature", TargetBranch: "dev"})
+
+	im.Apply(jm, "work", items)
+	assert.Equal(t, ImportOk, items[0].Result)
+	assert.Equal(t, ImportExists, items[1].Result)
+
+	j := jm.FindByBranch("work", "VM-1_x_dev")
+	assert.NotNil(t, j)
+	assert.Equal(t, []string{"dev"}, j.TargetBranch)
+	assert.Equal(t, model.JiraBranchPushed, j.BranchList[0].State)
+	assert.Equal(t, "feature", j.BranchList[0].DevBranch)
+}
@@ -0,0 +1,100 @@
+package repo
+
+import (
+	"fmt"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/sirupsen/logrus"
+)
+
+// IsMerged 判断临时分支是否已合入目标分支，只读取远程分支，不会切换分支或修改工作区
+// 依次检查：MR已合并、临时分支已包含在目标分支中、分支的commit(按patch-id)都已出现在目标分支中
+func (g *GitRepo) IsMerged(j *model.Jira, jb *model.JiraBranch) (merged bool, err error) {
+	target := "origin/" + jb.TargetBranch
+	if err = g.FetchBranch(jb.TargetBranch); err != nil {
+		return false, fmt.Errorf("拉取目标分支失败:%s", jb.TargetBranch)
+	}
+
+	if merged = g.mrMerged(jb); merged {
+		return
+	}
+
+	//临时分支可能已被删除
+	if g.FetchBranch(jb.BranchName) == nil && g.IsAncestor("origin/"+jb.BranchName, target) {
+		logrus.Debugf("%s 已包含在 %s 中", jb.BranchName, target)
+		return true, nil
+	}
+
+	return g.patchIdsMerged(j, jb, target)
+}
+
+// mrMerged 最近一次MR是否已合并，获取失败时视为未合并，继续通过git判断
+func (g *GitRepo) mrMerged(jb *model.JiraBranch) bool {
+	n := len(jb.MergeRequests)
+	if n == 0 || g.forge == nil {
+		return false
+	}
+
+	mr, err := g.forge.GetMergeRequest(jb.MergeRequests[n-1].MrId)
+	if err != nil {
+		logrus.Debugf("获取MR失败:%s,%v", jb.MergeRequests[n-1].WebUrl, err)
+		return false
+	}
+	return mr.State == MrStateMerged
+}
+
+// patchIdsMerged 分支推送的commit是否都已出现在目标分支中，squash以外的合并方式、手动cherry-pick都能识别
+func (g *GitRepo) patchIdsMerged(j *model.Jira, jb *model.JiraBranch, target string) (merged bool, err error) {
+	var (
+		revs      = []string{"--no-walk=unsorted"}
+		want      []string
+		patchIds  map[string]string
+		tgtPatchs = map[string]bool{}
+	)
+
+	for _, ci := range jb.Commits {
+		//推送时目标分支中已包含或冲突时跳过的commit不需要判断
+		if ci.TargetExists || ci.TargetCommitId != "" || ci.Skipped {
+			continue
+		}
+		if ci.PatchId != "" {
+			want = append(want, ci.PatchId)
+			continue
+		}
+		revs = append(revs, ci.CommitId)
+	}
+
+	//旧记录中没有patch-id，按commitId计算
+	if len(revs) > 1 {
+		if patchIds, err = g.PatchIds(revs...); err != nil {
+			logrus.Debugf("计算patch-id失败:%v", err)
+			return false, nil
+		}
+		for _, id := range revs[1:] {
+			if patchIds[id] == "" {
+				return false, nil
+			}
+			want = append(want, patchIds[id])
+		}
+	}
+
+	if len(want) == 0 {
+		return false, nil
+	}
+
+	if patchIds, err = g.PatchIds(append(GrepKey(j.GetCherryPickMsg()), target)...); err != nil {
+		return
+	}
+	for _, patchId := range patchIds {
+		tgtPatchs[patchId] = true
+	}
+
+	for _, patchId := range want {
+		if !tgtPatchs[patchId] {
+			return false, nil
+		}
+	}
+
+	logrus.Debugf("%s 的commit都已出现在 %s 中", jb.BranchName, target)
+	return true, nil
+}
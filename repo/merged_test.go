@@ -0,0 +1,53 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitRepo_IsMerged(t *testing.T) {
+	dir := newTestRepo(t)
+	g := NewGitRepo(dir, "")
+	j := &model.Jira{JiraID: "VM-1", CommitType: model.CommitTypeJira}
+
+	gitRun(t, dir, "checkout", "-b", "feature")
+	c1 := gitCommit(t, dir, "b.txt", "b\n", "VM-1 add b")
+
+	//未合并
+	gitRun(t, dir, "checkout", "-b", "VM-1_x_dev", "origin/dev")
+	gitRun(t, dir, "cherry-pick", c1)
+	gitRun(t, dir, "push", "origin", "VM-1_x_dev")
+	jb := &model.JiraBranch{BranchName: "VM-1_x_dev", TargetBranch: "dev", Commits: []*model.CommitInfo{{CommitId: c1}}}
+	merged, err := g.IsMerged(j, jb)
+	assert.Nil(t, err)
+	assert.False(t, merged)
+
+	//合入后删除临时分支，旧记录没有patch-id，通过commitId计算
+	gitRun(t, dir, "checkout", "-b", "dev", "origin/dev")
+	gitCommit(t, dir, "d.txt", "d\n", "dev")
+	gitRun(t, dir, "cherry-pick", c1)
+	gitRun(t, dir, "push", "origin", "dev", ":VM-1_x_dev")
+	merged, err = g.IsMerged(j, jb)
+	assert.Nil(t, err)
+	assert.True(t, merged)
+
+	//临时分支已包含在目标分支中
+	gitRun(t, dir, "checkout", "-b", "VM-2_x_dev", "origin/dev")
+	gitRun(t, dir, "push", "origin", "VM-2_x_dev")
+	merged, err = g.IsMerged(&model.Jira{JiraID: "VM-2", CommitType: model.CommitTypeJira}, &model.JiraBranch{BranchName: "VM-2_x_dev", TargetBranch: "dev"})
+	assert.Nil(t, err)
+	assert.True(t, merged)
+
+	//MR已合并
+	g.forge = &stubForge{mrs: []*MergeRequest{{Id: 1, State: MrStateMerged}}}
+	merged, err = g.IsMerged(&model.Jira{JiraID: "VM-3", CommitType: model.CommitTypeJira}, &model.JiraBranch{BranchName: "VM-3_x_dev", TargetBranch: "dev", MergeRequests: []*model.MrInfo{{MrId: 1}}})
+	assert.Nil(t, err)
+	assert.True(t, merged)
+
+	//没有MR和commit时无法判断
+	merged, err = g.IsMerged(&model.Jira{JiraID: "VM-4", CommitType: model.CommitTypeJira}, &model.JiraBranch{BranchName: "VM-4_x_dev", TargetBranch: "dev"})
+	assert.Nil(t, err)
+	assert.False(t, merged)
+}
@@ -0,0 +1,147 @@
+package repo
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/goeoeo/gitx/model"
+)
+
+const pickListHelp = `
+# 按从上到下的顺序依次 cherry-pick，可以调整行的顺序
+# 命令:
+#   pick <commit> = cherry-pick 该commit，可以直接添加不带JiraID的commit
+#   drop <commit> = 去掉该commit，删除整行效果相同
+# 以 # 开头的行会被忽略，清空所有commit会退出本次push
+`
+
+// pickItem 编辑后的一行
+type pickItem struct {
+	Action string
+	Rev    string
+}
+
+// editCommits 在编辑器中编辑待cherry-pick的commit，支持删除、添加、调整顺序
+func editCommits(g *GitRepo, header string, cis []*model.CommitInfo) (res []*model.CommitInfo, err error) {
+	var (
+		f     *os.File
+		b     []byte
+		items []*pickItem
+		ci    *model.CommitInfo
+	)
+
+	if f, err = os.CreateTemp("", "gitx-pick-*.txt"); err != nil {
+		return
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.WriteString(formatPickList(header, cis)); err != nil {
+		_ = f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+
+	if err = runEditor(f.Name()); err != nil {
+		return
+	}
+
+	if b, err = os.ReadFile(f.Name()); err != nil {
+		return
+	}
+
+	if items, err = parsePickList(string(b)); err != nil {
+		return
+	}
+
+	for _, item := range items {
+		if item.Action != "pick" {
+			continue
+		}
+
+		if ci = findCommit(cis, item.Rev); ci == nil {
+			if ci, err = g.GetCommit(item.Rev); err != nil {
+				return
+			}
+		}
+		res = append(res, ci)
+	}
+	return
+}
+
+// formatPickList 生成编辑器中的内容，cis 为 cherry-pick 的顺序
+func formatPickList(header string, cis []*model.CommitInfo) string {
+	var sb strings.Builder
+	for _, v := range cis {
+		sb.WriteString(fmt.Sprintf("pick %s %s\n", shortCommitId(v.CommitId), v.Desc))
+	}
+	sb.WriteString("\n# " + header)
+	sb.WriteString(pickListHelp)
+	return sb.String()
+}
+
+// parsePickList 解析编辑后的内容
+func parsePickList(content string) (items []*pickItem, err error) {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("无法解析:%s", line)
+		}
+
+		action := fields[0]
+		switch action {
+		case "p", "pick":
+			action = "pick"
+		case "d", "drop":
+			action = "drop"
+		default:
+			return nil, fmt.Errorf("不支持的命令:%s", line)
+		}
+
+		items = append(items, &pickItem{Action: action, Rev: fields[1]})
+	}
+
+	return items, scanner.Err()
+}
+
+func findCommit(cis []*model.CommitInfo, rev string) *model.CommitInfo {
+	for _, v := range cis {
+		if strings.HasPrefix(v.CommitId, rev) {
+			return v
+		}
+	}
+	return nil
+}
+
+func runEditor(file string) error {
+	editor := "vi"
+	for _, env := range []string{"GIT_EDITOR", "VISUAL", "EDITOR"} {
+		if v := os.Getenv(env); v != "" {
+			editor = v
+			break
+		}
+	}
+
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, "--", file)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func shortCommitId(commitId string) string {
+	if len(commitId) > 10 {
+		return commitId[0:10]
+	}
+	return commitId
+}
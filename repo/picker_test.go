@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePickList(t *testing.T) {
+	items, err := parsePickList(`
+pick 1111111 VM-1 a
+# pick 2222222 VM-1 b
+d 3333333 VM-1 c
+p 4444444
+
+# 注释
+`)
+	assert.Nil(t, err)
+	assert.Len(t, items, 3)
+	assert.Equal(t, &pickItem{Action: "pick", Rev: "1111111"}, items[0])
+	assert.Equal(t, &pickItem{Action: "drop", Rev: "3333333"}, items[1])
+	assert.Equal(t, &pickItem{Action: "pick", Rev: "4444444"}, items[2])
+
+	_, err = parsePickList("squash 1111111")
+	assert.NotNil(t, err)
+	_, err = parsePickList("pick")
+	assert.NotNil(t, err)
+}
+
+func TestEditCommits(t *testing.T) {
+	dir := newTestRepo(t)
+	a := gitCommit(t, dir, "b.txt", "b\n", "VM-1 add b")
+	b := gitCommit(t, dir, "c.txt", "c\n", "VM-1 add c")
+	c := gitCommit(t, dir, "d.txt", "d\n", "other add d")
+
+	cis := []*model.CommitInfo{
+		{CommitId: a, Desc: "VM-1 add b", CreateTime: time.Now()},
+		{CommitId: b, Desc: "VM-1 add c", CreateTime: time.Now()},
+	}
+
+	//调换顺序，去掉b，添加不带JiraID的c
+	script := filepath.Join(t.TempDir(), "editor.sh")
+	content := "#!/bin/sh\nprintf 'pick " + c[0:8] + "\\ndrop " + b[0:8] + "\\npick " + a[0:8] + "\\n' > \"$1\"\n"
+	assert.Nil(t, os.WriteFile(script, []byte(content), 0755))
+	t.Setenv("GIT_EDITOR", script)
+
+	res, err := editCommits(NewGitRepo(dir, ""), "work feature=>dev", cis)
+	assert.Nil(t, err)
+	assert.Len(t, res, 2)
+	assert.Equal(t, c, res[0].CommitId)
+	assert.Equal(t, "other add d", res[0].Desc)
+	assert.Equal(t, cis[0], res[1])
+
+	assert.Contains(t, formatPickList("work feature=>dev", cis), "pick "+a[0:10]+" VM-1 add b\n")
+}
@@ -0,0 +1,137 @@
+package repo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/goeoeo/gitx/util"
+)
+
+type (
+	// PushPlan push --dry-run 计算出的执行计划，不会切换分支、推送或调用代码托管平台的写接口
+	PushPlan struct {
+		Project   string
+		JiraId    string
+		DevBranch string
+		Branches  []*BranchPlan
+	}
+
+	// BranchPlan 单个目标分支的执行计划
+	BranchPlan struct {
+		Branch    string              //命令行中指定的分支
+		TgtBranch string              //通过BranchAlias翻译后的目标分支
+		NewBranch string              //临时分支
+		Commits   []*model.CommitInfo //待cherry-pick的commit，按cherry-pick的顺序排列
+		Skipped   []*model.CommitInfo //目标分支已包含或本地记录中已推送过的commit
+		CreateMr  bool                //是否自动创建mr
+		AutoMerge bool                //是否自动合并mr
+		Error     string              //无法计算计划的原因
+	}
+)
+
+// Plan 计算push的执行计划
+func (rp *RepoPatch) Plan() (plan *PushPlan, err error) {
+	var (
+		jm *model.JiraMgr
+	)
+
+	if jm, err = model.LoadJiraMgr(); err != nil {
+		return
+	}
+
+	//只读，不保存
+	jira := jm.GetOrCreate(rp.Repo.Name, rp.Patch.JiraId, rp.Patch.CommitType, rp.Patch.CommitMsg)
+
+	plan = &PushPlan{
+		Project:   rp.Repo.Name,
+		JiraId:    rp.Patch.JiraId,
+		DevBranch: rp.Patch.DevBranch,
+	}
+
+	//与push使用相同的别名翻译，TgtBranchs中对应的为命令行中指定的分支
+	for i, tgtBranch := range rp.Patch.GetTgtBranchs() {
+		pRepo := NewRepoPush(rp.Repo, rp.config, tgtBranch, jira, rp.ignoreLocalCommit)
+		plan.Branches = append(plan.Branches, pRepo.plan(rp.Patch.TgtBranchs[i]))
+	}
+	return
+}
+
+func (r *RepoPush) plan(branch string) *BranchPlan {
+	tgtBranch := r.RepoPushPatch.TgtBranch
+	bp := &BranchPlan{
+		Branch:    branch,
+		TgtBranch: tgtBranch,
+		NewBranch: r.newBranchName(r.RepoPushPatch.JiraId, r.RepoPushPatch.JiraDesc, tgtBranch),
+		CreateMr:  r.repo.CreateMr && r.GitRepo.forge != nil,
+	}
+	bp.AutoMerge = bp.CreateMr && util.ContainString(r.repo.AutoMergeBranchList, tgtBranch)
+
+	if r.RepoPushPatch.DevBranch == tgtBranch {
+		bp.Error = fmt.Sprintf("当前分支与目标分支不能是一个%s", tgtBranch)
+		return bp
+	}
+
+	//与push一样先拉取目标分支，避免与过期的远程分支比较
+	if err := r.GitRepo.FetchBranch(tgtBranch); err != nil {
+		bp.Error = fmt.Sprintf("拉取目标分支失败:%s", tgtBranch)
+		return bp
+	}
+
+	cis, skipped, err := r.selectCommits()
+	if err != nil {
+		bp.Error = err.Error()
+		return bp
+	}
+
+	//git log 为倒序，按时间正序 cherry-pick
+	for i := len(cis) - 1; i >= 0; i-- {
+		bp.Commits = append(bp.Commits, cis[i])
+	}
+	for i := len(skipped) - 1; i >= 0; i-- {
+		bp.Skipped = append(bp.Skipped, skipped[i])
+	}
+	return bp
+}
+
+// Rows 计划的汇总表格行
+func (p *PushPlan) Rows() (rows [][]string) {
+	for _, bp := range p.Branches {
+		branch := bp.TgtBranch
+		if bp.Branch != bp.TgtBranch {
+			branch = fmt.Sprintf("%s(%s)", bp.TgtBranch, bp.Branch)
+		}
+
+		status := bp.Error
+		if status == "" && len(bp.Commits) == 0 {
+			status = "无需推送"
+		}
+
+		rows = append(rows, []string{p.Project, p.JiraId, fmt.Sprintf("%s=>%s", p.DevBranch, branch), bp.NewBranch,
+			fmt.Sprintf("%d", len(bp.Commits)), fmt.Sprintf("%d", len(bp.Skipped)), yesNo(bp.CreateMr), yesNo(bp.AutoMerge), status})
+	}
+	return
+}
+
+// CommitRows 计划的commit表格行
+func (p *PushPlan) CommitRows() (rows [][]string) {
+	for _, bp := range p.Branches {
+		for _, v := range bp.Commits {
+			rows = append(rows, []string{p.Project, bp.TgtBranch, "pick", shortCommitId(v.CommitId),
+				strings.Replace(v.Desc, " ", "", -1), v.CreateTime.Format("2006-01-02 15:04:05"), ""})
+		}
+		for _, v := range bp.Skipped {
+			rows = append(rows, []string{p.Project, bp.TgtBranch, "skip(已包含)", shortCommitId(v.CommitId),
+				strings.Replace(v.Desc, " ", "", -1), v.CreateTime.Format("2006-01-02 15:04:05"),
+				util.Default(shortCommitId(v.TargetCommitId), "本地记录")})
+		}
+	}
+	return
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "是"
+	}
+	return "否"
+}
@@ -0,0 +1,118 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultProtectedBranches 未配置 protected_branches 时受保护的分支
+var DefaultProtectedBranches = []string{"master", "qa", "staging", "staging_iaas", "QCE_*"}
+
+// ErrProtectedBranch 受保护的分支不允许删除或重建
+var ErrProtectedBranch = errors.New("受保护的分支不能删除或重建")
+
+var (
+	protectionMu    sync.Mutex
+	protectionCache = map[string]*BranchProtection{} //仓库地址或路径 => 受保护的分支
+)
+
+// BranchProtection 受保护的分支，gitx不会删除或重建这些分支
+// 支持通配符(* 匹配任意字符，与GitLab一致)和正则(以 re: 开头)
+type BranchProtection struct {
+	Patterns []string
+	res      []*regexp.Regexp
+}
+
+func NewBranchProtection(patterns []string) *BranchProtection {
+	p := &BranchProtection{}
+	for _, v := range patterns {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+
+		re, err := compileBranchPattern(v)
+		if err != nil {
+			logrus.Warnf("忽略无效的受保护分支规则:%s,%v", v, err)
+			continue
+		}
+		p.Patterns = append(p.Patterns, v)
+		p.res = append(p.res, re)
+	}
+	return p
+}
+
+func compileBranchPattern(pattern string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(pattern, "re:") {
+		return regexp.Compile(strings.TrimPrefix(pattern, "re:"))
+	}
+
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.Compile("^" + expr + "$")
+}
+
+// Protected 分支是否受保护
+func (p *BranchProtection) Protected(branch string) bool {
+	for _, re := range p.res {
+		if re.MatchString(branch) {
+			return true
+		}
+	}
+	return false
+}
+
+// BranchProtection 仓库受保护的分支：全局配置(未配置时使用默认值)、项目配置和代码托管平台上受保护的分支
+func (c *Config) BranchProtection(path, url string, forge Forge) *BranchProtection {
+	patterns := DefaultProtectedBranches
+	if c.ProtectedBranches != nil {
+		patterns = c.ProtectedBranches
+	}
+	patterns = append([]string{}, patterns...)
+
+	for _, r := range c.Repo {
+		if (url != "" && r.Url == url) || (path != "" && r.Path == path) {
+			patterns = append(patterns, r.ProtectedBranches...)
+		}
+	}
+
+	if forge != nil {
+		names, err := forge.ListProtectedBranches()
+		if err != nil {
+			logrus.Debugf("获取代码托管平台受保护的分支失败:%s,%v", url, err)
+		}
+		patterns = append(patterns, names...)
+	}
+	return NewBranchProtection(patterns)
+}
+
+// Protected 分支是否受保护，同一仓库只从代码托管平台获取一次
+func (g *GitRepo) Protected(branch string) bool {
+	key := g.Url
+	if key == "" {
+		key = g.Path
+	}
+
+	protectionMu.Lock()
+	p, ok := protectionCache[key]
+	if !ok {
+		p = GetConfig().BranchProtection(g.Path, g.Url, g.forge)
+		protectionCache[key] = p
+	}
+	protectionMu.Unlock()
+
+	return p.Protected(branch)
+}
+
+// checkProtected 受保护的分支返回错误
+func (g *GitRepo) checkProtected(branch string) error {
+	if g.Protected(branch) {
+		return fmt.Errorf("%w:%s", ErrProtectedBranch, branch)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// protectedForge 只返回受保护的分支
+type protectedForge struct {
+	Forge
+	names []string
+}
+
+func (f *protectedForge) ListProtectedBranches() ([]string, error) {
+	return f.names, nil
+}
+
+func TestBranchProtection_Protected(t *testing.T) {
+	p := NewBranchProtection([]string{"main", "release/*", `re:^v\d+\.x$`, "re:("})
+	assert.Equal(t, []string{"main", "release/*", `re:^v\d+\.x$`}, p.Patterns)
+
+	assert.True(t, p.Protected("main"))
+	assert.True(t, p.Protected("release/6.1"))
+	assert.True(t, p.Protected("v6.x"))
+	assert.False(t, p.Protected("mainline"))
+	assert.False(t, p.Protected("v6.1"))
+	assert.False(t, p.Protected("VM-1_x_release/6.1"))
+
+	p = NewBranchProtection(DefaultProtectedBranches)
+	assert.True(t, p.Protected("QCE_6.1"))
+	assert.False(t, p.Protected("dev"))
+}
+
+func TestConfig_BranchProtection(t *testing.T) {
+	c := &Config{Repo: map[string]*Repo{
+		"work":  {Path: "/work", Url: "https://git.example.com/g/work.git", ProtectedBranches: []string{"v*"}},
+		"other": {Path: "/other", ProtectedBranches: []string{"other"}},
+	}}
+
+	p := c.BranchProtection("/work", "", &protectedForge{names: []string{"stable/*"}})
+	assert.Equal(t, append(append([]string{}, DefaultProtectedBranches...), "v*", "stable/*"), p.Patterns)
+
+	c.ProtectedBranches = []string{"main"}
+	p = c.BranchProtection("", "https://git.example.com/g/work.git", nil)
+	assert.Equal(t, []string{"main", "v*"}, p.Patterns)
+}
+
+func TestGitRepo_DelBranchProtected(t *testing.T) {
+	dir := newTestRepo(t)
+	gitRun(t, dir, "branch", "qa")
+	gitRun(t, dir, "push", "origin", "qa")
+	g := NewGitRepo(dir, "")
+
+	//只保护远程分支，本地分支可以删除后从远程重建
+	assert.True(t, errors.Is(g.DelRemoteBranch("qa"), ErrProtectedBranch))
+	assert.Nil(t, g.DelLocalBranch("qa"))
+
+	gitRun(t, dir, "branch", "qa", "origin/qa")
+	cfg.Patch.TgtBranchs = []string{"qa"}
+	assert.Nil(t, NewRepoPatch(&Repo{Name: "work", Path: dir}, cfg).Pull(true))
+	assert.Equal(t, "qa", AutoBranch(dir))
+	assert.True(t, g.RefExists("refs/remotes/origin/qa"))
+}
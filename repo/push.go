@@ -7,25 +7,40 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/goeoeo/gitx/model"
+	"github.com/goeoeo/gitx/tracker"
 	"github.com/goeoeo/gitx/util"
 	"github.com/sirupsen/logrus"
-	"github.com/xanzy/go-gitlab"
 )
 
 var (
-	ErrStop = errors.New("don`t continue")
+	ErrStop          = errors.New("don`t continue")
+	ErrConflictAbort = errors.New("cherry-pick 冲突，已放弃本次push")
 )
 
+// cherry-pick冲突的处理策略，为空时交互式处理
+const (
+	ConflictPolicyAbort      = "abort"       //放弃整个push
+	ConflictPolicySkip       = "skip"        //跳过冲突的commit
+	ConflictPolicyPause      = "pause"       //暂停，处理完冲突后通过 push --continue 继续
+	ConflictPolicyFailBranch = "fail-branch" //放弃当前目标分支，继续后续分支
+)
+
+var ConflictPolicies = []string{ConflictPolicyAbort, ConflictPolicySkip, ConflictPolicyPause, ConflictPolicyFailBranch}
+
 type RepoPatch struct {
 	Repo              *Repo
 	Patch             *Patch
 	jm                *model.JiraMgr
 	config            *Config
 	ignoreLocalCommit bool
+	jobs              chan struct{} //并发push的令牌，为空时依次执行
+	gitMu             sync.Mutex    //同一仓库的fetch、工作树等操作串行执行
 }
 
 func NewRepoPatch(repo *Repo, config *Config) *RepoPatch {
@@ -43,57 +58,318 @@ func (rp *RepoPatch) IgnoreLocalCommit(f bool) *RepoPatch {
 }
 
 func (rp *RepoPatch) Push() (results []*RepoPushResult, err error) {
+	//进度文件无法读取时不能覆盖，避免丢失未完成的push
+	_, err = LoadPushSession(rp.config, rp.Repo.Name)
+	switch {
+	case err == nil:
+		return nil, fmt.Errorf("%s: %w", rp.Repo.Name, ErrSessionExist)
+	case !errors.Is(err, ErrNoSession):
+		return nil, fmt.Errorf("%s: %w", rp.Repo.Name, err)
+	}
+
+	//先预测各目标分支的冲突情况
+	if !rp.Patch.SkipCheck {
+		if err = rp.confirmCheck(); err != nil {
+			if errors.Is(err, ErrStop) {
+				logrus.Debugf("user stop")
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+
+	session := NewPushSession(rp.config, rp.Repo.Name, rp.ignoreLocalCommit)
+	if err = session.Save(); err != nil {
+		return nil, err
+	}
+
+	return rp.run(session)
+}
+
+// Continue 继续未完成的push，在冲突处理完成后调用
+func (rp *RepoPatch) Continue() (results []*RepoPushResult, err error) {
 	var (
-		rpr *RepoPushResult
+		session *PushSession
 	)
 
-	if rp.jm, err = model.NewJiraMgr(); err != nil {
-		return nil, err
+	if session, err = LoadPushSession(rp.config, rp.Repo.Name); err != nil {
+		return nil, fmt.Errorf("%s: %w", rp.Repo.Name, err)
+	}
+
+	return rp.run(session)
+}
+
+// Abort 放弃未完成的push，清理工作树和进度
+func (rp *RepoPatch) Abort() (err error) {
+	var (
+		session *PushSession
+	)
+
+	if session, err = LoadPushSession(rp.config, rp.Repo.Name); err != nil {
+		return fmt.Errorf("%s: %w", rp.Repo.Name, err)
+	}
+
+	git := NewGitRepo(rp.Repo.Path, rp.Repo.Url)
+	for _, bs := range session.Branches {
+		if bs.Worktree == "" || bs.Finished() {
+			continue
+		}
+
+		wt := git.Worktree(bs.Worktree)
+		_ = wt.CherryPickAbort()
+		if err = git.WorktreeRemove(bs.Worktree); err != nil {
+			logrus.Debugf("remove worktree faild: %s, err: %v \n", bs.Worktree, err)
+		}
 	}
 
-	jira := rp.jm.GetOrCreate(rp.Repo.Name, rp.Patch.JiraId, rp.Patch.CommitType, rp.Patch.CommitMsg)
-	jira.AddTargetBranch(rp.Patch.GetPlanTgtBranchList())
+	return session.Remove()
+}
+
+// run 处理session中未完成的目标分支，配置了并发数时各目标分支并发执行
+func (rp *RepoPatch) run(session *PushSession) (results []*RepoPushResult, err error) {
+	var (
+		jira    *model.Jira
+		pending []*BranchSession
+		errs    []error
+	)
 
-	for _, tgtBranch := range rp.Patch.GetTgtBranchs() {
-		pRepo := NewRepoPush(rp.Repo, rp.config, tgtBranch, jira, rp.ignoreLocalCommit)
-		if err = pRepo.GitRepo.LsRemote(); err != nil {
+	if rp.jm == nil {
+		if rp.jm, err = model.NewJiraMgr(); err != nil {
+			return nil, err
+		}
+	}
+
+	jira = rp.jm.GetOrCreate(rp.Repo.Name, session.JiraId, session.CommitType, session.CommitMsg)
+	rp.jm.WithLock(func() {
+		jira.AddTargetBranch(rp.Patch.GetPlanTgtBranchList())
+		if issue := rp.issue(session.JiraId); issue != nil {
+			jira.Summary = issue.Summary
+			jira.Status = issue.Status
+		}
+	})
+
+	for _, bs := range session.Branches {
+		if !bs.Finished() {
+			pending = append(pending, bs)
+		}
+	}
+
+	if len(pending) > 0 {
+		if err = NewGitRepo(rp.Repo.Path, rp.Repo.Url).LsRemote(); err != nil {
 			logrus.Debugf("git remote connection exception, please check; repo: %s \n", rp.Repo.Path)
 			return nil, err
 		}
+	}
 
-		if rpr, err = pRepo.push(); err != nil {
-			if errors.Is(err, ErrStop) {
-				logrus.Debugf("user stop")
-				return nil, nil
+	if rp.jobs == nil {
+		for _, bs := range pending {
+			if err = rp.pushBranch(session, jira, bs); err != nil {
+				errs = append(errs, err)
+				break
 			}
-
-			logrus.Debugf("git repo push faild: repo: %s, target branch [%s], err: %v \n",
-				rp.Repo.Path, tgtBranch, err)
-			return
 		}
+	} else {
+		errs = rp.pushBranches(session, jira, pending)
+	}
 
-		if rpr.TargetBranch != "" {
-			results = append(results, rpr)
+	for _, e := range errs {
+		if errors.Is(e, ErrConflictAbort) {
+			if aErr := rp.Abort(); aErr != nil {
+				logrus.Debugf("abort push faild: repo: %s, err: %v \n", rp.Repo.Path, aErr)
+			}
+			return nil, e
 		}
+	}
 
+	for _, e := range errs {
+		if !errors.Is(e, ErrStop) {
+			return nil, e
+		}
 	}
 
-	//推送完成后会到自己的dev分支
-	if err := NewGitRepo(rp.Repo.Path, rp.Repo.Url).SwitchBranch(rp.Patch.DevBranch); err != nil {
-		return nil, err
+	if len(errs) > 0 {
+		logrus.Debugf("user stop")
+		fmt.Printf("push 已暂停，处理完成后执行 gitx push -p %s --continue 继续，或 --abort 放弃\n", rp.Repo.Name)
+		return nil, nil
 	}
 
-	if len(jira.BranchList) == 0 {
+	results = session.Results()
+	if len(jira.BranchList) == 0 && len(results) == 0 {
+		//没有推送任何分支，进度无需恢复
+		if err = session.Remove(); err != nil {
+			return nil, err
+		}
 		return nil, fmt.Errorf("未提取到提交信息，项目目录搞错了？")
 	}
-	//保存
+
+	//保存成功后再删除进度，保存失败时可以 --continue 重试
 	if err = rp.jm.Save(); err != nil {
 		return nil, err
 	}
+	if err = session.Remove(); err != nil {
+		return nil, err
+	}
+
+	rp.commentIssue(session, results)
+	if err = rp.updateIssue(session, jira, results); err != nil {
+		return nil, err
+	}
+	rp.syncShared()
+	return
+}
+
+// syncShared 配置了共享时，将jira记录同步到仓库中，失败时只提示
+func (rp *RepoPatch) syncShared() {
+	if !rp.Repo.ShareTracking {
+		return
+	}
+
+	if err := NewGitRepo(rp.Repo.Path, rp.Repo.Url).SyncShared(rp.jm, rp.Repo.Name); err != nil {
+		logrus.Warnf("同步共享的jira记录失败:%s,%v", rp.Repo.Name, err)
+	}
+}
+
+// issue 配置了Jira时获取的任务信息
+func (rp *RepoPatch) issue(jiraId string) *tracker.Issue {
+	if rp.Patch.Issue == nil || rp.Patch.Issue.Key != jiraId {
+		return nil
+	}
+	return rp.Patch.Issue
+}
+
+// commentIssue 在任务下评论各目标分支的MR地址
+func (rp *RepoPatch) commentIssue(session *PushSession, results []*RepoPushResult) {
+	var (
+		lines []string
+	)
+
+	t := rp.config.Tracker(rp.Repo, session.JiraId)
+	if t == nil || session.CommitType != model.CommitTypeJira || len(results) == 0 {
+		return
+	}
+
+	lines = append(lines, fmt.Sprintf("gitx push %s %s:", rp.Repo.Name, session.DevBranch))
+	for _, v := range results {
+		lines = append(lines, fmt.Sprintf("- %s: %s %s", v.TargetBranch, util.Default(v.MergeUrl, v.NewBranch), v.Status()))
+	}
+
+	if err := t.AddComment(session.JiraId, strings.Join(lines, "\n")); err != nil {
+		logrus.Warnf("评论任务失败:%s,%v", session.JiraId, err)
+	}
+}
+
+// updateIssue 按配置流转任务：MR合并失败时添加标签，所有目标分支合入后流转任务
+func (rp *RepoPatch) updateIssue(session *PushSession, jira *model.Jira, results []*RepoPushResult) (err error) {
+	var (
+		resolved bool
+	)
+
+	t := rp.config.Tracker(rp.Repo, session.JiraId)
+	if t == nil || session.CommitType != model.CommitTypeJira {
+		return
+	}
+
+	for _, v := range results {
+		if v.MergeRes == MergeResFail {
+			if mErr := t.MarkFailed(session.JiraId); mErr != nil {
+				logrus.Warnf("任务添加标签失败:%s,%v", session.JiraId, mErr)
+			}
+			break
+		}
+	}
+
+	rp.jm.WithLock(func() {
+		resolved = rp.config.ResolveIssue(jira)
+	})
+	if !resolved {
+		return nil
+	}
+	return rp.jm.Save()
+}
+
+// pushBranches 并发处理目标分支，并发数由jobs限制，返回各分支的错误
+// 某个分支按abort策略放弃时，尚未开始的分支不再执行
+func (rp *RepoPatch) pushBranches(session *PushSession, jira *model.Jira, pending []*BranchSession) (errs []error) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		aborted bool
+	)
+
+	for _, bs := range pending {
+		wg.Add(1)
+		go func(bs *BranchSession) {
+			defer wg.Done()
+
+			rp.jobs <- struct{}{}
+			defer func() { <-rp.jobs }()
+
+			mu.Lock()
+			skip := aborted
+			mu.Unlock()
+			if skip {
+				return
+			}
+
+			if err := rp.pushBranch(session, jira, bs); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				aborted = aborted || errors.Is(err, ErrConflictAbort)
+				mu.Unlock()
+			}
+		}(bs)
+	}
 
+	wg.Wait()
 	return
 }
 
+// pushBranch 处理单个目标分支，完成后保存jira记录和进度
+func (rp *RepoPatch) pushBranch(session *PushSession, jira *model.Jira, bs *BranchSession) (err error) {
+	var (
+		rpr *RepoPushResult
+	)
+
+	pRepo := NewRepoPush(rp.Repo, rp.config, bs.TgtBranch, jira, session.IgnoreLocalCommit).withSession(session)
+	pRepo.jm = rp.jm
+	pRepo.gitMu = &rp.gitMu
+	pRepo.showProgress = rp.jobs != nil
+
+	pRepo.progress("开始")
+	if rpr, err = pRepo.push(bs); err != nil {
+		_ = session.SaveBranch(bs)
+		if errors.Is(err, ErrStop) {
+			pRepo.progress("已暂停")
+			return
+		}
+
+		pRepo.progress("失败:%v", err)
+		logrus.Debugf("git repo push faild: repo: %s, target branch [%s], err: %v \n",
+			rp.Repo.Path, bs.TgtBranch, err)
+		return
+	}
+
+	bs.Result = rpr
+	if bs.State != SessionStateFailed {
+		bs.State = SessionStateDone
+	}
+	pRepo.progress(util.Default(rpr.FailReason, "完成"))
+
+	if bs.Worktree != "" {
+		unlock := pRepo.lockGit()
+		if rErr := pRepo.GitRepo.WorktreeRemove(bs.Worktree); rErr != nil {
+			logrus.Debugf("remove worktree faild: %s, err: %v \n", bs.Worktree, rErr)
+		}
+		unlock()
+	}
+
+	//每完成一个分支保存一次，避免异常退出丢失记录
+	if err = rp.jm.Save(); err != nil {
+		return
+	}
+	return session.SaveBranch(bs)
+}
+
 type (
 	RepoPush struct {
 		GitRepo           *GitRepo
@@ -101,7 +377,11 @@ type (
 		jr                *model.Jira
 		config            *Config
 		repo              *Repo
+		session           *PushSession
 		ignoreLocalCommit bool
+		jm                *model.JiraMgr //并发push时用于加锁读写jira记录
+		gitMu             *sync.Mutex
+		showProgress      bool //并发push时打印各分支的进度
 	}
 	RepoPushPatch struct {
 		DevBranch string
@@ -118,6 +398,8 @@ type (
 		MergeUrl     string
 		NewBranch    string
 		OutCommits   []*model.CommitInfo
+		Failed       bool   //该目标分支处理失败
+		FailReason   string //失败原因
 	}
 )
 
@@ -138,150 +420,166 @@ func NewRepoPush(r *Repo, config *Config, tgtBranch string, jr *model.Jira, igno
 	}
 }
 
+// withSession 使用session中记录的jira和开发分支
+func (r *RepoPush) withSession(s *PushSession) *RepoPush {
+	r.session = s
+	r.RepoPushPatch.DevBranch = s.DevBranch
+	r.RepoPushPatch.JiraId = s.JiraId
+	r.RepoPushPatch.JiraDesc = s.JiraDesc
+	return r
+}
+
 // https://cwiki.yunify.com/pages/viewpage.action?pageId=132639361
-// 1. 基于 目标分支 创建一个新分支 JiraId_JiraDesc_TgtBranch
+// 1. 基于 目标分支 创建一个新分支 JiraId_JiraDesc_TgtBranch，并检出到 ~/.patch/worktree 下的独立工作树
 // 2. 从 DevBranch 找出 jira 的 commit。 所以要求 commit message 用 jira id 开头
-// 3. 用 git cherry-pick 把 commit 提交到 JiraId_JiraDesc_TgtBranch
+// 3. 在工作树中用 git cherry-pick 把 commit 提交到 JiraId_JiraDesc_TgtBranch
 // 4. 生成 JiraId_JiraDesc_TgtBranch 到 TgtBranch 的 merge request url.
-func (r *RepoPush) push() (result *RepoPushResult, err error) {
-	var (
-		mergeReq               string
-		newBranch              string
-		tmpCommits, cis        []*model.CommitInfo
-		ret                    bool
-		mrInfo                 *model.MrInfo
-		mergeRes               string
-		newBranchRebaseSuccess bool
-	)
-
+// 每一步的进度记录在bs中，中断后可以从断点继续
+func (r *RepoPush) push(bs *BranchSession) (result *RepoPushResult, err error) {
 	logrus.Debugf("begin push repo [%s] branch [%s] ... \n", r.GitRepo.Path, r.RepoPushPatch.TgtBranch)
 
-	tgtBranch := r.RepoPushPatch.TgtBranch
-	devBranch := r.RepoPushPatch.DevBranch
-	jiraId := r.RepoPushPatch.JiraId
-	repoPath := r.GitRepo.Path
-	result = &RepoPushResult{
-		DevBranch: devBranch,
-	}
-
-	if devBranch == tgtBranch {
-		return nil, fmt.Errorf("当前分支与目标分支不能是一个%s", devBranch)
+	if bs.Result == nil {
+		bs.Result = &RepoPushResult{
+			DevBranch: r.RepoPushPatch.DevBranch,
+		}
 	}
+	result = bs.Result
 
-	newBranch = r.newBranchName(jiraId, r.RepoPushPatch.JiraDesc, tgtBranch)
-
-	if err = r.GitRepo.SwitchBranch(tgtBranch); err != nil {
-		logrus.Debugf("switch git branch faild: repo: %s, branch [%s], err: %v \n", r.GitRepo.Path, tgtBranch, err)
-		//切换目标分支失败，从远端拉取
-		if err = r.GitRepo.NewBranchFromRemote(tgtBranch); err != nil {
-			return nil, err
+	if bs.State == SessionStatePending {
+		if err = r.prepare(bs); err != nil {
+			return
 		}
+	}
 
-	} else {
-		//拉取远端分支到本地
-		if err = r.GitRepo.Pull(); err != nil {
-			logrus.Debugf("git pull faild: repo: %s, branch [%s], err: %v \n", r.GitRepo.Path, tgtBranch, err)
-			//切回开发分支
-			if err = r.GitRepo.SwitchBranch(devBranch); err != nil {
-				return
-			}
-
-			//远端分支和本地不一致，删除本地分支
-			if err = r.GitRepo.DelLocalBranch(tgtBranch); err != nil {
-				return
-			}
+	if bs.State == SessionStateDone {
+		return
+	}
 
-			//切换目标分支失败，从远端拉取
-			if err = r.GitRepo.NewBranchFromRemote(tgtBranch); err != nil {
-				return nil, err
-			}
+	wt := r.GitRepo.Worktree(bs.Worktree)
+	if bs.State == SessionStatePaused {
+		if err = r.resume(wt, bs); err != nil {
+			return
 		}
+	}
 
+	if err = r.cherryPick(wt, bs); err != nil {
+		return
 	}
 
-	// 但由于是目标分支，删除是否合适或风险大小，再考虑一下吧。
-	if ret, err = r.GitRepo.HasBranch(newBranch); err != nil {
-		logrus.Debugf("git has branch faild: repo: %s, branch [%s], err: %v \n", r.GitRepo.Path, newBranch, err)
+	if bs.State == SessionStateFailed {
 		return
 	}
 
-	if ret && newBranch != devBranch {
-		//尝试对远端分支进行rebase,如果能变基成功，说明是可合并的
-		if err = r.GitRepo.SwitchBranch(newBranch); err != nil {
-			return
-		}
+	err = r.finish(wt, bs)
+	return
+}
 
-		if err = r.GitRepo.Rebase(tgtBranch); err != nil {
-			//变基失败，取消
-			if err = r.GitRepo.RebaseAbort(); err != nil {
-				return
-			}
+// prepare 挑选commit并准备临时分支的工作树
+func (r *RepoPush) prepare(bs *BranchSession) (err error) {
+	var (
+		newBranch           string
+		cis, skipped, picks []*model.CommitInfo
+		wt                  *GitRepo
+	)
 
-			if err = r.GitRepo.SwitchBranch(tgtBranch); err != nil {
-				return
-			}
+	tgtBranch := r.RepoPushPatch.TgtBranch
+	devBranch := r.RepoPushPatch.DevBranch
+	jiraId := r.RepoPushPatch.JiraId
 
-			//rebase失败，删除本地临时分支，让研发人员处理冲突
-			if err = r.GitRepo.DelLocalBranch(newBranch); err != nil {
-				logrus.Debugf("git del local branch faild: repo: %s, branch [%s], err: %v \n", r.GitRepo.Path, newBranch, err)
-				return
-			}
-		} else {
-			newBranchRebaseSuccess = true
-		}
+	if devBranch == tgtBranch {
+		return fmt.Errorf("当前分支与目标分支不能是一个%s", devBranch)
+	}
 
-		if err = r.GitRepo.SwitchBranch(tgtBranch); err != nil {
-			return
-		}
+	newBranch = r.newBranchName(jiraId, r.RepoPushPatch.JiraDesc, tgtBranch)
 
+	//拉取最新的目标分支，用于比较patch-id和创建临时分支
+	unlock := r.lockGit()
+	if err = r.GitRepo.FetchBranch(tgtBranch); err == nil {
+		cis, skipped, err = r.selectCommits()
 	}
-
-	if !newBranchRebaseSuccess {
-		if err = r.GitRepo.NewBranch(newBranch); err != nil {
-			logrus.Debugf("git create branch faild: repo: %s, branch [%s], err: %v \n", r.GitRepo.Path, newBranch, err)
-			return
-		}
+	unlock()
+	if err != nil {
+		return
 	}
 
-	if err = r.GitRepo.SwitchBranch(devBranch); err != nil {
-		logrus.Debugf("switch git branch faild: repo: %s, branch [%s], err: %v \n", r.GitRepo.Path, devBranch, err)
+	if picks, err = r.confirmCommits(newBranch, cis, skipped); err != nil {
 		return
 	}
 
-	if tmpCommits, err = r.GitRepo.GetCommitInfo(r.jr.GetCherryPickMsg()); err != nil {
-		logrus.Debugf("git jira %s commits faild: repo: %s, branch [%s], err: %v \n", jiraId, r.GitRepo.Path, devBranch, err)
+	//所有commit目标分支都已包含，无需推送
+	if len(picks) == 0 {
+		bs.State = SessionStateDone
+		return r.saveSession(bs)
+	}
+
+	//在独立的工作树中完成cherry-pick，不影响开发者当前的工作区
+	unlock = r.lockGit()
+	wt, err = r.prepareWorktree(newBranch, tgtBranch)
+	unlock()
+	if err != nil {
+		logrus.Debugf("prepare worktree faild: repo: %s, branch [%s], err: %v \n", r.GitRepo.Path, newBranch, err)
 		return
 	}
 
-	for _, commit := range tmpCommits {
-		if !r.ignoreLocalCommit && r.jr.BranchContainCommit(tgtBranch, commit.CommitId) {
-			continue
+	bs.Commits = picks
+	bs.NewBranch = newBranch
+	bs.Worktree = wt.Path
+	bs.Index = 0
+	bs.State = SessionStatePicking
+
+	return r.saveSession(bs)
+}
+
+// confirmCommits 打印待cherry-pick的commit，由用户确认或编辑，返回按cherry-pick顺序排列的commit
+// 所有commit目标分支都已包含时返回空
+func (r *RepoPush) confirmCommits(newBranch string, cis, skipped []*model.CommitInfo) (picks []*model.CommitInfo, err error) {
+	tgtBranch := r.RepoPushPatch.TgtBranch
+	devBranch := r.RepoPushPatch.DevBranch
+	jiraId := r.RepoPushPatch.JiraId
+
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	if len(skipped) > 0 {
+		var rows [][]string
+		for _, v := range skipped {
+			rows = append(rows, []string{r.repo.Name, strings.Replace(v.Desc, " ", "", -1), tgtBranch,
+				shortCommitId(v.CommitId), util.Default(shortCommitId(v.TargetCommitId), "本地记录")})
 		}
-		cis = append(cis, commit)
+
+		fmt.Println("以下commit目标分支已包含，跳过:")
+		util.PrintTable(rows, []string{"项目", "描述", "目标分支", "commit", "目标commit"})
+	}
+
+	//所有commit目标分支都已包含，无需推送
+	if len(cis) == 0 && len(skipped) > 0 {
+		return
 	}
 
 	if len(cis) == 0 {
-		return nil, fmt.Errorf("未提取到提交信息，项目目录搞错了？当前目录:%s,当前分支:%s", r.repo.Path, r.config.Patch.DevBranch)
+		return nil, fmt.Errorf("未提取到提交信息，项目目录搞错了？当前目录:%s,当前分支:%s", r.repo.Path, devBranch)
+	}
+
+	//git log 为倒序，按时间正序 cherry-pick
+	for i := len(cis) - 1; i >= 0; i-- {
+		picks = append(picks, cis[i])
 	}
 
-	var rows [][]string
-	for _, v := range cis {
-		commitID := ""
-		if len(v.CommitId) > 10 {
-			commitID = v.CommitId[0:10]
+	printCommits := func() {
+		var rows [][]string
+		for _, v := range picks {
+			rows = append(rows, []string{r.repo.Name, jiraId, strings.Replace(v.Desc, " ", "", -1),
+				fmt.Sprintf("%s=>%s", devBranch, tgtBranch), newBranch, shortCommitId(v.CommitId), v.CreateTime.Format("2006-01-02 15:04:05")})
 		}
 
-		rows = append(rows, []string{r.repo.Name, jiraId, strings.Replace(v.Desc, " ", "", -1),
-			fmt.Sprintf("%s=>%s", devBranch, tgtBranch), newBranch, commitID, v.CreateTime.Format("2006-01-02 15:04:05")})
+		util.PrintTable(rows, []string{"项目", "JiraID", "描述", "分支", "临时分支", "commit", "时间"})
 	}
 
-	util.PrintTable(rows, []string{"项目", "JiraID", "描述", "分支", "临时分支", "commit", "时间"})
-
-	// 交互式 确认 commit, todo: add/remove/re commit
+	// 交互式 确认 commit，可在编辑器中删除、添加、调整顺序
 	showCommit := func() error {
 	loop:
 		reader := bufio.NewReader(os.Stdin)
-		fmt.Println("请确认 commit 是否正确，程序将会按照倒叙依次 cherry-pick, 输入 y 继续， 输入 n 程序退出。")
+		fmt.Println("请确认 commit 是否正确，程序将会按照表格顺序依次 cherry-pick, 输入 y 继续， 输入 e 编辑commit列表， 输入 n 程序退出。")
 		char, _, err := reader.ReadRune()
 		if err != nil {
 			log.Println(err)
@@ -290,6 +588,18 @@ func (r *RepoPush) push() (result *RepoPushResult, err error) {
 		switch char {
 		case 'y':
 			return nil
+		case 'e':
+			edited, err := editCommits(r.GitRepo, fmt.Sprintf("%s %s=>%s", r.repo.Name, devBranch, tgtBranch), picks)
+			if err != nil {
+				fmt.Printf("编辑commit列表失败:%v\n", err)
+				goto loop
+			}
+			if len(edited) == 0 {
+				return ErrStop
+			}
+			picks = edited
+			printCommits()
+			goto loop
 		case 'n':
 			return ErrStop
 		default:
@@ -297,67 +607,226 @@ func (r *RepoPush) push() (result *RepoPushResult, err error) {
 		}
 	}
 
-	if err = showCommit(); err != nil {
+	printCommits()
+	if !r.config.Patch.AssumeYes {
+		if err = showCommit(); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// selectCommits 从开发分支中挑选jira相关的commit，cis为待cherry-pick的commit，均为倒序
+// skipped为目标分支中已有相同修改(patch-id相同)或本地记录中已推送过的commit
+func (r *RepoPush) selectCommits() (cis, skipped []*model.CommitInfo, err error) {
+	var (
+		all     []*model.CommitInfo
+		present map[string]bool
+	)
+
+	if all, present, err = r.jiraCommits(); err != nil {
+		return
+	}
+	for _, commit := range all {
+		if present[commit.CommitId] {
+			skipped = append(skipped, commit)
+		} else {
+			cis = append(cis, commit)
+		}
+	}
+	return
+}
+
+// jiraCommits 开发分支中jira相关的所有commit，倒序排列，present中为应跳过的commit
+func (r *RepoPush) jiraCommits() (tmpCommits []*model.CommitInfo, present map[string]bool, err error) {
+	var (
+		revs       []string
+		patchIds   map[string]string
+		tgtCommits = map[string]string{} //patchId => 目标分支的commitId
+	)
+
+	tgtBranch := r.RepoPushPatch.TgtBranch
+	devBranch := r.RepoPushPatch.DevBranch
+
+	if tmpCommits, err = r.GitRepo.GetRefCommitInfo(devBranch, r.jr.GetCherryPickMsg()); err != nil {
+		logrus.Debugf("git jira %s commits faild: repo: %s, branch [%s], err: %v \n", r.RepoPushPatch.JiraId, r.GitRepo.Path, devBranch, err)
 		return
 	}
 
-	if err = r.GitRepo.SwitchBranch(newBranch); err != nil {
-		logrus.Debugf("git create branch faild: repo: %s, branch [%s], err: %v \n",
-			r.GitRepo.Path, newBranch, err)
+	if len(tmpCommits) == 0 {
+		return
+	}
+
+	//rebase后commitId会变化，通过patch-id判断目标分支中是否已有相同的修改
+	revs = append(revs, "--no-walk=unsorted")
+	for _, commit := range tmpCommits {
+		revs = append(revs, commit.CommitId)
+	}
+	if patchIds, err = r.GitRepo.PatchIds(revs...); err != nil {
+		return
+	}
+	for _, commit := range tmpCommits {
+		commit.PatchId = patchIds[commit.CommitId]
+	}
+
+	//cherry-pick会保留commit message，只比较目标分支中同一个jira的commit
+	if r.GitRepo.RefExists("origin/" + tgtBranch) {
+		if patchIds, err = r.GitRepo.PatchIds(append(GrepKey(r.jr.GetCherryPickMsg()), "origin/"+tgtBranch)...); err != nil {
+			return
+		}
+		for commitId, patchId := range patchIds {
+			tgtCommits[patchId] = commitId
+		}
+	}
+
+	present = map[string]bool{}
+	for _, commit := range tmpCommits {
+		if tgtCommitId, ok := tgtCommits[commit.PatchId]; ok && commit.PatchId != "" {
+			commit.TargetCommitId = tgtCommitId
+			present[commit.CommitId] = true
+			continue
+		}
+
+		if !r.ignoreLocalCommit {
+			r.withJira(func() {
+				present[commit.CommitId] = r.jr.BranchContainCommit(tgtBranch, commit)
+			})
+		}
+	}
+	return
+}
+
+// resume 冲突处理完成后继续，当前commit视为已cherry-pick
+func (r *RepoPush) resume(wt *GitRepo, bs *BranchSession) (err error) {
+	commit := bs.Commits[bs.Index]
+	if err = wt.CherryPickContinue(); err != nil {
+		return fmt.Errorf("冲突未处理完成，请在 %s 中处理冲突:%w", wt.Path, err)
+	}
+
+	bs.Result.AddCommits(commit)
+	bs.Index++
+	bs.State = SessionStatePicking
+	return r.saveSession(bs)
+}
+
+// skip 跳过冲突的commit并继续，commit标记为已跳过记录在结果中
+func (r *RepoPush) skip(wt *GitRepo, bs *BranchSession) (err error) {
+	commit := bs.Commits[bs.Index]
+	if err = wt.CherryPickSkip(); err != nil {
 		return
 	}
 
+	commit.Skipped = true
+	bs.Result.AddCommits(commit)
+	bs.Index++
+	bs.State = SessionStatePicking
+	return r.saveSession(bs)
+}
+
+// cherryPick 从断点开始依次 cherry-pick
+func (r *RepoPush) cherryPick(wt *GitRepo, bs *BranchSession) (err error) {
+	tgtBranch := r.RepoPushPatch.TgtBranch
+
 	checkCommit := func(commit *model.CommitInfo) error {
+		promptMu.Lock()
+		defer promptMu.Unlock()
 	checkLoop:
 		//  等待用户手动处理冲突
 		reader1 := bufio.NewReader(os.Stdin)
 		fmt.Printf("\n cherry-pick 冲突: %s, commitID:%s ,时间:%s\n", commit.Desc, commit.CommitId[0:10], commit.CreateTime.Format("2006-01-02 15:04:05"))
-		fmt.Println("请手动处理冲突, 处理完成后 输入 y 继续; 输入 s 会自动执行 cherry-pick --skip 并继续后续; 输入 n 程序退出")
+		fmt.Printf("请在工作目录 %s 中手动处理冲突并执行 git cherry-pick --continue\n", wt.Path)
+		fmt.Println("处理完成后 输入 y 继续; 输入 s 会自动执行 cherry-pick --skip 并继续后续; 输入 n 程序退出，稍后可通过 gitx push --continue 继续")
 		char, _, err := reader1.ReadRune()
 		if err != nil {
 			log.Println(err)
-			return nil
+			return ErrStop
 		}
 		switch char {
 		case 'y':
-			result.AddCommits(commit)
-			return nil
+			return r.resume(wt, bs)
 		case 'n':
 			return ErrStop
 		case 's':
-			result.AddCommits(commit)
-			return r.GitRepo.CherryPickSkip()
+			return r.skip(wt, bs)
 		default:
 			goto checkLoop
 		}
 	}
 
-	for i := len(cis) - 1; i >= 0; i-- {
-		commit := cis[i]
+	for bs.Index < len(bs.Commits) {
+		commit := bs.Commits[bs.Index]
+		r.progress("cherry-pick %d/%d %s", bs.Index+1, len(bs.Commits), shortCommitId(commit.CommitId))
 		skip := false
-		if skip, err = r.GitRepo.CherryPick(commit.CommitId); err == nil {
-			if !skip {
-				result.AddCommits(commit)
-			} else {
+		if skip, err = wt.CherryPick(commit.CommitId); err == nil {
+			if skip {
 				fmt.Printf("目标分支已包含该commit[%s,%s]\n", tgtBranch, commit.CommitId[0:10])
 				commit.TargetExists = true
-				result.AddCommits(commit)
+			}
+			bs.Result.AddCommits(commit)
+			bs.Index++
+			if err = r.saveSession(bs); err != nil {
+				return
 			}
 			continue
 		}
 		logrus.Debugf("git cherry-pick commit [%s] faild: repo: %s, branch [%s], err: %v \n",
-			commit.CommitId, r.GitRepo.Path, tgtBranch, err)
+			commit.CommitId, wt.Path, tgtBranch, err)
 
-		if err = checkCommit(commit); err != nil {
+		//先记录冲突，终端异常退出后也能恢复
+		bs.State = SessionStatePaused
+		if err = r.saveSession(bs); err != nil {
+			return
+		}
+
+		switch r.config.Patch.ConflictPolicy {
+		case ConflictPolicySkip:
+			fmt.Printf("cherry-pick 冲突，跳过commit[%s,%s]\n", tgtBranch, commit.CommitId[0:10])
+			err = r.skip(wt, bs)
+		case ConflictPolicyPause:
+			fmt.Printf("\n cherry-pick 冲突: %s, commitID:%s，请在工作目录 %s 中处理冲突\n", commit.Desc, commit.CommitId[0:10], wt.Path)
+			err = ErrStop
+		case ConflictPolicyAbort:
+			_ = wt.CherryPickAbort()
+			err = fmt.Errorf("%w: %s=>%s, commitID:%s", ErrConflictAbort, r.RepoPushPatch.DevBranch, tgtBranch, commit.CommitId[0:10])
+		case ConflictPolicyFailBranch:
+			_ = wt.CherryPickAbort()
+			bs.State = SessionStateFailed
+			bs.Result.Fail(fmt.Sprintf("cherry-pick 冲突:%s", commit.CommitId[0:10]))
+			bs.Result.TargetBranch = tgtBranch
+			bs.Result.NewBranch = bs.NewBranch
+			bs.Result.Project = r.jr.Project
+			return r.saveSession(bs)
+		default:
+			err = checkCommit(commit)
+		}
+
+		if err != nil {
 			return
 		}
 	}
 
+	return
+}
+
+// finish 推送临时分支，创建MR并记录到jira
+func (r *RepoPush) finish(wt *GitRepo, bs *BranchSession) (err error) {
+	var (
+		mergeReq string
+		mrInfo   *model.MrInfo
+		mergeRes string
+	)
+
+	tgtBranch := r.RepoPushPatch.TgtBranch
+	devBranch := r.RepoPushPatch.DevBranch
+	newBranch := bs.NewBranch
+	result := bs.Result
+
+	r.progress("推送临时分支 %s", newBranch)
 	// 先删远程，再 push， 简化流程，避免冲突造成的额外工作。
 	//_ = r.GitRepo.DelRemoteBranch(newBranch)
-	if err = r.GitRepo.Push(newBranch, tgtBranch); err != nil {
+	if err = wt.Push(newBranch, tgtBranch); err != nil {
 		logrus.Debugf("git push faild: repo: %s, branch [%s], err: %v \n",
-			r.GitRepo.Path, newBranch, err)
+			wt.Path, newBranch, err)
 		return
 	}
 
@@ -373,12 +842,23 @@ func (r *RepoPush) push() (result *RepoPushResult, err error) {
 		Merged:       false,
 		Commits:      result.OutCommits,
 	}
+	for _, v := range bs.Commits {
+		jb.Selection = append(jb.Selection, v.CommitId)
+	}
+	if issue := r.config.Patch.Issue; issue != nil && issue.Key == r.RepoPushPatch.JiraId {
+		jb.LinkInfo = &model.LinkInfoItem{
+			LinkType: r.config.Tracker(r.repo, issue.Key).Name(),
+			IssueId:  issue.Key,
+			Summary:  issue.Summary,
+			Status:   issue.Status,
+		}
+	}
 
 	mergeReq = r.GitRepo.NewMergeReq(newBranch, tgtBranch)
 
-	//自动创建mr
-	if r.repo.CreateMr {
-		//util.PrintJson(jb)
+	//自动创建mr，未配置代码托管平台时只生成手动创建mr的地址
+	if r.repo.CreateMr && r.GitRepo.forge != nil {
+		r.progress("创建MR")
 		if mrInfo, err = r.GitRepo.CreateMergeRequest(jb.Desc(true), jb.BranchName, jb.TargetBranch); err != nil {
 			return
 		}
@@ -388,6 +868,7 @@ func (r *RepoPush) push() (result *RepoPushResult, err error) {
 		mergeReq = mrInfo.WebUrl
 		//自动合并
 		if util.ContainString(r.repo.AutoMergeBranchList, jb.TargetBranch) {
+			r.progress("合并MR %s", mrInfo.WebUrl)
 			if mergeRes, err = r.GitRepo.AcceptMergeRequest(mrInfo.MrId); err != nil {
 				return
 			}
@@ -404,11 +885,14 @@ func (r *RepoPush) push() (result *RepoPushResult, err error) {
 		}
 
 	}
+	jb.Merged = result.MergeRes == MergeResOk
 
-	r.jr.AttachBranch(tgtBranch).Append(jb)
+	r.withJira(func() {
+		r.jr.AttachBranch(tgtBranch).Append(jb)
+	})
 
 	logrus.Debugf("git push ok: jiraId: %s repo: %s, branch [%s] to branch [%s]; merge url:\n %v \n",
-		jiraId, repoPath, newBranch, tgtBranch, mergeReq)
+		r.RepoPushPatch.JiraId, r.GitRepo.Path, newBranch, tgtBranch, mergeReq)
 
 	result.NewBranch = newBranch
 	result.MergeUrl = mergeReq
@@ -417,11 +901,95 @@ func (r *RepoPush) push() (result *RepoPushResult, err error) {
 	return
 }
 
+// promptMu 并发push时，同一时间只有一个分支与用户交互
+var promptMu sync.Mutex
+
+// progress 并发push时打印分支的进度
+func (r *RepoPush) progress(format string, args ...any) {
+	if !r.showProgress {
+		return
+	}
+	fmt.Printf("[%s %s] %s\n", r.repo.Name, r.RepoPushPatch.TgtBranch, fmt.Sprintf(format, args...))
+}
+
+// lockGit 锁定仓库级别的git操作，返回解锁函数
+func (r *RepoPush) lockGit() func() {
+	if r.gitMu == nil {
+		return func() {}
+	}
+	r.gitMu.Lock()
+	return r.gitMu.Unlock
+}
+
+// withJira 读写jira记录
+func (r *RepoPush) withJira(fn func()) {
+	if r.jm == nil {
+		fn()
+		return
+	}
+	r.jm.WithLock(fn)
+}
+
+func (r *RepoPush) saveSession(bs *BranchSession) error {
+	if r.session == nil {
+		return nil
+	}
+	return r.session.SaveBranch(bs)
+}
+
+// prepareWorktree 基于最新的远端目标分支准备临时分支的工作树
+// 本地已存在临时分支时尝试变基到目标分支上，能变基成功说明是可合并的，直接复用
+func (r *RepoPush) prepareWorktree(newBranch, tgtBranch string) (wt *GitRepo, err error) {
+	var (
+		ret bool
+	)
+
+	//临时分支会被重置和强制推送
+	if err = r.GitRepo.checkProtected(newBranch); err != nil {
+		return
+	}
+
+	base := "origin/" + tgtBranch
+	wtPath := r.worktreePath(newBranch)
+	wt = r.GitRepo.Worktree(wtPath)
+
+	//清理上次异常退出残留的工作树
+	if util.FileExists(wtPath) {
+		_ = r.GitRepo.WorktreeRemove(wtPath)
+	}
+
+	if ret, err = r.GitRepo.HasBranch(newBranch); err != nil {
+		return
+	}
+
+	if ret && newBranch != r.RepoPushPatch.DevBranch {
+		if err = r.GitRepo.WorktreeAdd(wtPath, newBranch, ""); err == nil {
+			if err = wt.Rebase(base); err == nil {
+				return
+			}
+
+			//变基失败，取消后基于目标分支重建临时分支，让研发人员处理冲突
+			_ = wt.RebaseAbort()
+			if err = r.GitRepo.WorktreeRemove(wtPath); err != nil {
+				return
+			}
+		}
+	}
+
+	err = r.GitRepo.WorktreeAdd(wtPath, newBranch, base)
+	return
+}
+
+// worktreePath 临时分支对应的工作树目录
+func (r *RepoPush) worktreePath(newBranch string) string {
+	return filepath.Join(r.config.HomeDir, "worktree", r.repo.Name, newBranch)
+}
+
 // waitMrMerged 登台MR合并
 func (r *RepoPush) waitMrMerged(mrId int) (merged bool) {
 	var (
 		err error
-		mr  *gitlab.MergeRequest
+		mr  *MergeRequest
 	)
 	defer func() {
 		if err != nil {
@@ -439,7 +1007,7 @@ func (r *RepoPush) waitMrMerged(mrId int) (merged bool) {
 			break
 		}
 
-		if mr.State == "merged" {
+		if mr.State == MrStateMerged {
 			merged = true
 			break
 		}
@@ -475,7 +1043,7 @@ func (r *RepoPush) AutoMergeBranchHook() {
 
 func (r *RepoPush) newBranchName(jiraID, jiraDesc, tgtBranch string) string {
 	m := map[string]string{
-		"jiraID":    jiraID,
+		"jiraID":    tracker.BranchKey(jiraID),
 		"jiraDesc":  jiraDesc,
 		"tgtBranch": tgtBranch,
 	}
@@ -492,10 +1060,37 @@ func (rpr *RepoPushResult) AddCommits(commit *model.CommitInfo) {
 	rpr.OutCommits = append(rpr.OutCommits, commit)
 }
 
+func (rpr *RepoPushResult) Fail(reason string) {
+	rpr.Failed = true
+	rpr.FailReason = reason
+}
+
+// Status 推送结果的描述
+func (rpr *RepoPushResult) Status() string {
+	if rpr.Failed {
+		return "失败:" + rpr.FailReason
+	}
+	if n := rpr.SkippedCommitsLen(); n > 0 {
+		return fmt.Sprintf("成功，冲突跳过%d个commit", n)
+	}
+	return "成功"
+}
+
 func (rpr *RepoPushResult) NewCommitsLen() int {
 	num := 0
 	for _, v := range rpr.OutCommits {
-		if !v.TargetExists {
+		if !v.TargetExists && !v.Skipped {
+			num++
+		}
+	}
+	return num
+}
+
+// SkippedCommitsLen 冲突时跳过的commit数
+func (rpr *RepoPushResult) SkippedCommitsLen() int {
+	num := 0
+	for _, v := range rpr.OutCommits {
+		if v.Skipped {
 			num++
 		}
 	}
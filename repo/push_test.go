@@ -1,9 +1,18 @@
 package repo
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/goeoeo/gitx/tracker"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
-	"testing"
 )
 
 func TestRepoPush_newBranchName(t *testing.T) {
@@ -28,3 +37,342 @@ func TestRepoPush_autoMergeBranchHook(t *testing.T) {
 	logrus.SetLevel(logrus.DebugLevel)
 	p.AutoMergeBranchHook()
 }
+
+// newTestRepo 创建一个带远端的本地仓库，远端包含 master 和 dev 分支
+func newTestRepo(t *testing.T) (dir string) {
+	t.Setenv("GIT_AUTHOR_NAME", "gitx")
+	t.Setenv("GIT_AUTHOR_EMAIL", "gitx@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "gitx")
+	t.Setenv("GIT_COMMITTER_EMAIL", "gitx@example.com")
+
+	root := t.TempDir()
+	origin := filepath.Join(root, "origin.git")
+	dir = filepath.Join(root, "work")
+	gitRun(t, root, "init", "--bare", "-b", "master", origin)
+	gitRun(t, origin, "config", "receive.advertisePushOptions", "true")
+	gitRun(t, root, "clone", origin, dir)
+	gitCommit(t, dir, "a.txt", "a\n", "init")
+	gitRun(t, dir, "push", "origin", "master")
+	gitRun(t, dir, "push", "origin", "master:dev")
+
+	cfg = &Config{
+		HomeDir: filepath.Join(root, ".patch"),
+		Patch:   &Patch{TmpBranchFmt: "{jiraID}_{jiraDesc}_{tgtBranch}", BranchAlias: map[string]string{}},
+		Repo:    map[string]*Repo{},
+	}
+	return
+}
+
+func gitRun(t *testing.T, dir string, args ...string) string {
+	ret, err := ExecCmd(dir, "git", args...)
+	if err != nil {
+		t.Fatalf("git %v: %s", args, ret.ErrStr)
+	}
+	return strings.TrimSpace(ret.Out)
+}
+
+func gitCommit(t *testing.T, dir, file, content, msg string) string {
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, file), []byte(content), 0644))
+	gitRun(t, dir, "add", file)
+	gitRun(t, dir, "commit", "-m", msg)
+	return gitRun(t, dir, "rev-parse", "HEAD")
+}
+
+func TestRepoPush_prepareWorktree(t *testing.T) {
+	dir := newTestRepo(t)
+	gitRun(t, dir, "checkout", "-b", "feature")
+	gitCommit(t, dir, "b.txt", "b\n", "VM-1 add b")
+	//开发者工作区中未提交的修改
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("dirty\n"), 0644))
+
+	cfg.Patch.DevBranch = "feature"
+	cfg.Patch.JiraId = "VM-1"
+	cfg.Patch.JiraDesc = "x"
+	p := NewRepoPush(&Repo{Name: "work", Path: dir}, cfg, "dev", nil, false)
+
+	wt, err := p.prepareWorktree("VM-1_x_dev", "dev")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(cfg.HomeDir, "worktree", "work", "VM-1_x_dev"), wt.Path)
+	assert.Equal(t, "VM-1_x_dev", AutoBranch(wt.Path))
+
+	_, err = wt.CherryPick(gitRun(t, dir, "rev-parse", "feature"))
+	assert.Nil(t, err)
+	assert.Nil(t, p.GitRepo.WorktreeRemove(wt.Path))
+
+	//开发者的分支和工作区不受影响
+	assert.Equal(t, "feature", AutoBranch(dir))
+	assert.Equal(t, "M a.txt", gitRun(t, dir, "status", "--porcelain"))
+
+	//临时分支已存在时复用
+	wt, err = p.prepareWorktree("VM-1_x_dev", "dev")
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(wt.Path, "b.txt"))
+	assert.Nil(t, err)
+	assert.Nil(t, p.GitRepo.WorktreeRemove(wt.Path))
+}
+
+// newConflictRepo 创建开发分支feature，其中VM-1的commit与dev分支冲突，与master不冲突
+func newConflictRepo(t *testing.T) (*Repo, string) {
+	t.Setenv("HOME", t.TempDir())
+	dir := newTestRepo(t)
+
+	gitRun(t, dir, "checkout", "-b", "dev", "origin/dev")
+	gitCommit(t, dir, "a.txt", "dev\n", "dev change a")
+	gitRun(t, dir, "push", "origin", "dev")
+
+	gitRun(t, dir, "checkout", "-b", "feature", "master")
+	gitCommit(t, dir, "a.txt", "feature\n", "VM-1 change a")
+	gitCommit(t, dir, "b.txt", "b\n", "VM-1 add b")
+
+	cfg.Patch.DevBranch = "feature"
+	cfg.Patch.JiraId = "VM-1"
+	cfg.Patch.JiraDesc = "x"
+	cfg.Patch.CommitType = "jira"
+	cfg.Patch.AssumeYes = true
+	cfg.Patch.TgtBranchs = []string{"dev", "master"}
+
+	r := &Repo{Name: "work", Path: dir}
+	cfg.Repo[r.Name] = r
+	return r, dir
+}
+
+func TestRepoPatch_PushFailBranch(t *testing.T) {
+	r, dir := newConflictRepo(t)
+	cfg.Patch.ConflictPolicy = ConflictPolicyFailBranch
+
+	results, err := NewRepoPatch(r, cfg).Push()
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.True(t, results[0].Failed)
+	assert.Equal(t, "dev", results[0].TargetBranch)
+	assert.False(t, results[1].Failed)
+	assert.Equal(t, 2, results[1].NewCommitsLen())
+
+	assert.Equal(t, "feature", AutoBranch(dir))
+	gitRun(t, dir, "rev-parse", "--verify", "origin/VM-1_x_master")
+	_, err = LoadPushSession(cfg, r.Name)
+	assert.ErrorIs(t, err, ErrNoSession)
+}
+
+func TestRepoPatch_PushPauseAndContinue(t *testing.T) {
+	r, dir := newConflictRepo(t)
+	cfg.Patch.ConflictPolicy = ConflictPolicyPause
+
+	results, err := NewRepoPatch(r, cfg).Push()
+	assert.Nil(t, err)
+	assert.Len(t, results, 0)
+
+	s, err := LoadPushSession(cfg, r.Name)
+	assert.Nil(t, err)
+	assert.Equal(t, SessionStatePaused, s.Branches[0].State)
+	assert.Equal(t, 0, s.Branches[0].Index)
+
+	//已存在未完成的push
+	_, err = NewRepoPatch(r, cfg).Push()
+	assert.ErrorIs(t, err, ErrSessionExist)
+
+	//在工作树中处理冲突
+	wt := s.Branches[0].Worktree
+	assert.Nil(t, os.WriteFile(filepath.Join(wt, "a.txt"), []byte("resolved\n"), 0644))
+	gitRun(t, wt, "add", "a.txt")
+
+	results, err = NewRepoPatch(r, cfg).Continue()
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, 2, results[0].NewCommitsLen())
+	assert.Equal(t, "resolved", gitRun(t, dir, "show", "origin/VM-1_x_dev:a.txt"))
+	assert.NoDirExists(t, wt)
+}
+
+func TestRepoPatch_PushSkip(t *testing.T) {
+	push := func(policy string) *RepoPushResult {
+		r, _ := newConflictRepo(t)
+		cfg.Patch.ConflictPolicy = policy
+
+		results, err := NewRepoPatch(r, cfg).Push()
+		assert.Nil(t, err)
+		assert.Len(t, results, 2)
+		return results[0]
+	}
+
+	skipped := push(ConflictPolicySkip)
+
+	//交互时输入 s 跳过
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+	pr, pw, err := os.Pipe()
+	assert.Nil(t, err)
+	_, _ = pw.WriteString("s\n")
+	_ = pw.Close()
+	os.Stdin = pr
+	interactive := push("")
+
+	for _, res := range []*RepoPushResult{skipped, interactive} {
+		assert.Equal(t, "dev", res.TargetBranch)
+		assert.Len(t, res.OutCommits, 2)
+		assert.True(t, res.OutCommits[0].Skipped)
+		assert.Equal(t, 1, res.NewCommitsLen())
+		assert.Equal(t, 1, res.SkippedCommitsLen())
+		assert.Equal(t, "成功，冲突跳过1个commit", res.Status())
+	}
+}
+
+func TestRepoPatch_PushCorruptSession(t *testing.T) {
+	r, _ := newConflictRepo(t)
+
+	//进度文件损坏时不覆盖
+	p := sessionPath(cfg, r.Name)
+	assert.Nil(t, os.MkdirAll(filepath.Dir(p), 0755))
+	assert.Nil(t, os.WriteFile(p, []byte("{"), 0644))
+
+	_, err := NewRepoPatch(r, cfg).Push()
+	assert.NotNil(t, err)
+	assert.NotErrorIs(t, err, ErrSessionExist)
+	b, _ := os.ReadFile(p)
+	assert.Equal(t, "{", string(b))
+}
+
+func TestRepoPatch_PushAbort(t *testing.T) {
+	r, dir := newConflictRepo(t)
+	cfg.Patch.ConflictPolicy = ConflictPolicyAbort
+
+	_, err := NewRepoPatch(r, cfg).Push()
+	assert.ErrorIs(t, err, ErrConflictAbort)
+	_, err = LoadPushSession(cfg, r.Name)
+	assert.ErrorIs(t, err, ErrNoSession)
+	assert.Len(t, strings.Split(gitRun(t, dir, "worktree", "list"), "\n"), 1)
+}
+
+func TestRepoPatch_Plan(t *testing.T) {
+	r, dir := newConflictRepo(t)
+	cfg.Patch.BranchAlias["d"] = "dev"
+	cfg.Patch.TgtBranchs = []string{"d", "master"}
+	cfg.Patch.DevBranch = "feature"
+	head := gitRun(t, dir, "rev-parse", "HEAD")
+
+	//master上已推送过最新的commit
+	jm, err := model.NewJiraMgr()
+	assert.Nil(t, err)
+	jm.GetOrCreate(r.Name, "VM-1", "jira", "").Append(&model.JiraBranch{
+		TargetBranch: "master",
+		Commits:      []*model.CommitInfo{{CommitId: head}},
+	})
+	assert.Nil(t, jm.Save())
+
+	plan, err := NewRepoPatch(r, cfg).Plan()
+	assert.Nil(t, err)
+	assert.Len(t, plan.Branches, 2)
+
+	bp := plan.Branches[0]
+	assert.Equal(t, "d", bp.Branch)
+	assert.Equal(t, "dev", bp.TgtBranch)
+	assert.Equal(t, "VM-1_x_dev", bp.NewBranch)
+	assert.Len(t, bp.Commits, 2)
+	assert.Equal(t, "VM-1 change a", bp.Commits[0].Desc)
+	assert.False(t, bp.CreateMr)
+
+	bp = plan.Branches[1]
+	assert.Len(t, bp.Commits, 1)
+	assert.Len(t, bp.Skipped, 1)
+	assert.Equal(t, head, bp.Skipped[0].CommitId)
+	assert.Len(t, plan.CommitRows(), 4)
+
+	//不切换分支，不推送
+	assert.Equal(t, "feature", AutoBranch(dir))
+	assert.Equal(t, "", gitRun(t, dir, "ls-remote", "--heads", "origin", "VM-1_*"))
+	_, err = LoadPushSession(cfg, r.Name)
+	assert.ErrorIs(t, err, ErrNoSession)
+
+	//其他人已在dev上提交相同的修改，计划前先拉取目标分支
+	other := filepath.Join(t.TempDir(), "other")
+	gitRun(t, filepath.Dir(dir), "clone", "-b", "dev", filepath.Join(filepath.Dir(dir), "origin.git"), other)
+	gitRun(t, other, "config", "user.email", "gitx@example.com")
+	gitRun(t, other, "config", "user.name", "gitx")
+	gitCommit(t, other, "b.txt", "b\n", "VM-1 add b")
+	gitRun(t, other, "push", "origin", "dev")
+
+	plan, err = NewRepoPatch(r, cfg).Plan()
+	assert.Nil(t, err)
+	assert.Len(t, plan.Branches[0].Commits, 1)
+	assert.Len(t, plan.Branches[0].Skipped, 1)
+}
+
+func TestRepoPush_selectCommitsPatchId(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := newTestRepo(t)
+	gitRun(t, dir, "checkout", "-b", "feature")
+	a := gitCommit(t, dir, "b.txt", "b\n", "VM-1 add b")
+	gitCommit(t, dir, "c.txt", "c\n", "VM-1 add c")
+
+	//dev上已有cherry-pick过来的相同修改，commitId不同
+	gitRun(t, dir, "checkout", "-b", "dev", "origin/dev")
+	gitCommit(t, dir, "d.txt", "d\n", "dev add d")
+	gitRun(t, dir, "cherry-pick", a)
+	picked := gitRun(t, dir, "rev-parse", "HEAD")
+	gitRun(t, dir, "push", "origin", "dev")
+	gitRun(t, dir, "checkout", "feature")
+
+	cfg.Patch.DevBranch = "feature"
+	cfg.Patch.JiraId = "VM-1"
+	jira := &model.Jira{JiraID: "VM-1"}
+	p := NewRepoPush(&Repo{Name: "work", Path: dir}, cfg, "dev", jira, false)
+
+	cis, skipped, err := p.selectCommits()
+	assert.Nil(t, err)
+	assert.Len(t, cis, 1)
+	assert.Equal(t, "VM-1 add c", cis[0].Desc)
+	assert.Len(t, skipped, 1)
+	assert.Equal(t, a, skipped[0].CommitId)
+	assert.Equal(t, picked, skipped[0].TargetCommitId)
+
+	//rebase后commitId变化，通过记录中的patch-id判断已推送
+	jira.Append(&model.JiraBranch{TargetBranch: "master", Commits: []*model.CommitInfo{cis[0]}})
+	t.Setenv("GIT_COMMITTER_DATE", "2020-01-01T00:00:00")
+	gitRun(t, dir, "rebase", "--force-rebase", "origin/master")
+	assert.NotEqual(t, cis[0].CommitId, gitRun(t, dir, "rev-parse", "HEAD"))
+	p = NewRepoPush(&Repo{Name: "work", Path: dir}, cfg, "master", jira, false)
+	cis, skipped, err = p.selectCommits()
+	assert.Nil(t, err)
+	assert.Len(t, cis, 1)
+	assert.Equal(t, "VM-1 add b", cis[0].Desc)
+	assert.Len(t, skipped, 1)
+	assert.Equal(t, "", skipped[0].TargetCommitId)
+}
+
+func TestRepoPatch_PushJira(t *testing.T) {
+	var comment map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rest/api/2/issue/VM-1", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"key":"VM-1","fields":{"summary":"Tpsc Migrate","status":{"name":"In Progress"}}}`))
+	})
+	mux.HandleFunc("/rest/api/2/issue/VM-1/comment", func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&comment))
+		w.WriteHeader(http.StatusCreated)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	r, dir := newConflictRepo(t)
+	cfg.Jira = &tracker.JiraConfig{BaseUrl: server.URL, Token: "token"}
+	cfg.Patch.JiraDesc = ""
+	cfg.Patch.ConflictPolicy = ConflictPolicyFailBranch
+	cfg.ParseJIRA("")
+	assert.Equal(t, "Tpsc_Migrate", cfg.Patch.JiraDesc)
+
+	results, err := NewRepoPatch(r, cfg).Push()
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	gitRun(t, dir, "rev-parse", "--verify", "origin/VM-1_Tpsc_Migrate_master")
+	assert.Contains(t, comment["body"], "- master: ")
+	assert.Contains(t, comment["body"], "- dev: ")
+
+	jm, err := model.NewJiraMgr()
+	assert.Nil(t, err)
+	jira := jm.GetOrCreate(r.Name, "VM-1", "", "")
+	assert.Equal(t, "VM-1:Tpsc Migrate[In Progress]", jira.GetDesc())
+	for _, jb := range jira.BranchList {
+		if jb.TargetBranch == "master" {
+			assert.Equal(t, &model.LinkInfoItem{LinkType: "jira", IssueId: "VM-1", Summary: "Tpsc Migrate", Status: "In Progress"}, jb.LinkInfo)
+		}
+	}
+}
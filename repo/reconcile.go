@@ -0,0 +1,159 @@
+package repo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/goeoeo/gitx/util"
+)
+
+type (
+	// Reconciler 对比项目的jira记录与分支、MR的实际状态
+	Reconciler struct {
+		git    *GitRepo
+		local  []string //本地分支
+		remote []string //远程分支
+	}
+
+	// Drift 记录与实际状态不一致的分支及建议的修正
+	Drift struct {
+		Project string
+		JiraID  string
+		Branch  *model.JiraBranch
+		Local   bool          //本地分支是否存在
+		Remote  bool          //远程分支是否存在
+		MR      *MergeRequest //最近一次MR，没有时为nil
+		NewMR   bool          //MR不在记录中，修正时补充
+		State   string        //建议的状态
+		Reason  string
+	}
+)
+
+// NewReconciler 拉取远程分支并读取本地、远程分支列表
+func NewReconciler(r *Repo) (rc *Reconciler, err error) {
+	rc = &Reconciler{git: NewGitRepo(r.Path, r.Url)}
+
+	if ret, err := ExecCmd(rc.git.Path, "git", "fetch", "--prune", "origin"); err != nil {
+		return nil, fmt.Errorf("拉取远程分支失败:%s", ret.ErrStr)
+	}
+
+	if rc.local, err = rc.git.GetBranchs(); err != nil {
+		return
+	}
+
+	branchs, err := rc.git.GetRemoteBranchs()
+	if err != nil {
+		return
+	}
+	for _, v := range branchs {
+		if !strings.Contains(v, "->") {
+			rc.remote = append(rc.remote, v)
+		}
+	}
+	return
+}
+
+// Check 检查分支记录，与实际状态一致时返回nil
+// MR的状态优先；没有MR时远程分支或commit(按patch-id)已包含在目标分支中视为已合并，本地和远程分支都已删除视为已关闭
+func (rc *Reconciler) Check(j *model.Jira, jb *model.JiraBranch) (d *Drift, err error) {
+	if jb.BranchName == "" || jb.State == model.JiraBranchPending {
+		return
+	}
+
+	d = &Drift{
+		Project: j.Project,
+		JiraID:  j.JiraID,
+		Branch:  jb,
+		Local:   util.ContainString(rc.local, jb.BranchName),
+		Remote:  util.ContainString(rc.remote, jb.BranchName),
+		State:   jb.State,
+	}
+
+	if d.MR, d.NewMR, err = rc.mergeRequest(jb); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case d.MR != nil:
+		switch d.MR.State {
+		case MrStateMerged:
+			d.State, d.Reason = model.JiraBranchMerged, "MR已合并"
+		case MrStateClosed:
+			d.State, d.Reason = model.JiraBranchClosed, "MR已关闭"
+		default:
+			d.State, d.Reason = model.JiraBranchPushed, "MR未合并"
+		}
+	case d.Remote && rc.git.IsAncestor("origin/"+jb.BranchName, "origin/"+jb.TargetBranch):
+		d.State, d.Reason = model.JiraBranchMerged, "分支已包含在目标分支中"
+	case jb.State != model.JiraBranchMerged:
+		var merged bool
+		if merged, err = rc.git.patchIdsMerged(j, jb, "origin/"+jb.TargetBranch); err != nil {
+			return nil, err
+		}
+		if merged {
+			d.State, d.Reason = model.JiraBranchMerged, "commit已出现在目标分支中"
+		} else if !d.Local && !d.Remote && jb.State == model.JiraBranchPushed {
+			d.State, d.Reason = model.JiraBranchClosed, "分支已删除且没有MR"
+		}
+	}
+
+	if d.State == jb.State && !d.NewMR {
+		return nil, nil
+	}
+	if d.State == jb.State {
+		d.Reason = "补充MR记录"
+	}
+	return
+}
+
+// mergeRequest 最近一次MR的状态，记录中没有MR时按分支查找
+func (rc *Reconciler) mergeRequest(jb *model.JiraBranch) (mr *MergeRequest, isNew bool, err error) {
+	var (
+		mrs []*MergeRequest
+	)
+
+	if rc.git.forge == nil {
+		return
+	}
+
+	if n := len(jb.MergeRequests); n > 0 {
+		if mr, err = rc.git.forge.GetMergeRequest(jb.MergeRequests[n-1].MrId); err != nil {
+			return nil, false, fmt.Errorf("获取MR失败:%s,%v", jb.MergeRequests[n-1].WebUrl, err)
+		}
+		return
+	}
+
+	if mrs, err = rc.git.forge.ListMergeRequests("", jb.BranchName, jb.TargetBranch); err != nil {
+		return nil, false, fmt.Errorf("获取MR失败:%s,%v", jb.BranchName, err)
+	}
+	for _, v := range mrs {
+		if mr == nil || v.Id > mr.Id {
+			mr = v
+		}
+	}
+	return mr, mr != nil, nil
+}
+
+// Apply 按建议修正记录
+func (d *Drift) Apply() {
+	if d.NewMR {
+		d.Branch.MergeRequests = append(d.Branch.MergeRequests, &model.MrInfo{
+			Title:  d.MR.Title,
+			MrId:   d.MR.Id,
+			WebUrl: d.MR.WebUrl,
+		})
+	}
+
+	if d.State != d.Branch.State {
+		d.Branch.SetState(d.State)
+	}
+}
+
+// MrState MR的状态描述
+func (d *Drift) MrState() string {
+	if d.MR == nil {
+		return "-"
+	}
+	return d.MR.State
+}
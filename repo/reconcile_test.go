@@ -0,0 +1,88 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubForge 按MR Id返回固定的MR
+type stubForge struct {
+	Forge
+	mrs []*MergeRequest
+}
+
+func (f *stubForge) GetMergeRequest(id int) (*MergeRequest, error) {
+	for _, mr := range f.mrs {
+		if mr.Id == id {
+			return mr, nil
+		}
+	}
+	return nil, ErrForgeNotSupported
+}
+
+func (f *stubForge) ListProtectedBranches() ([]string, error) {
+	return nil, nil
+}
+
+func (f *stubForge) ListMergeRequests(state, src, target string) (res []*MergeRequest, err error) {
+	for _, mr := range f.mrs {
+		if (state == "" || mr.State == state) && (src == "" || mr.SourceBranch == src) && (target == "" || mr.TargetBranch == target) {
+			res = append(res, mr)
+		}
+	}
+	return
+}
+
+func TestReconciler_Check(t *testing.T) {
+	dir := newTestRepo(t)
+	gitRun(t, dir, "checkout", "-b", "VM-1_x_dev", "origin/dev")
+	gitCommit(t, dir, "b.txt", "b\n", "VM-1 add b")
+	gitRun(t, dir, "push", "origin", "VM-1_x_dev")
+	//手动合入
+	gitRun(t, dir, "push", "origin", "VM-1_x_dev:dev")
+	gitRun(t, dir, "checkout", "-b", "VM-2_x_dev", "origin/dev")
+	gitCommit(t, dir, "c.txt", "c\n", "VM-2 add c")
+	gitRun(t, dir, "push", "origin", "VM-2_x_dev")
+	gitRun(t, dir, "checkout", "master")
+
+	rc, err := NewReconciler(&Repo{Name: "work", Path: dir})
+	assert.Nil(t, err)
+	rc.git.forge = &stubForge{mrs: []*MergeRequest{
+		{Id: 1, State: MrStateClosed, SourceBranch: "VM-2_x_dev", TargetBranch: "dev"},
+		{Id: 2, State: MrStateMerged, SourceBranch: "VM-4_x_dev", TargetBranch: "dev"},
+	}}
+
+	j := &model.Jira{Project: "work", JiraID: "VM-1"}
+	check := func(jb *model.JiraBranch) *Drift {
+		d, err := rc.Check(j, jb)
+		assert.Nil(t, err)
+		return d
+	}
+
+	d := check(&model.JiraBranch{BranchName: "VM-1_x_dev", TargetBranch: "dev", State: model.JiraBranchPushed})
+	assert.Equal(t, model.JiraBranchMerged, d.State)
+	assert.True(t, d.Local)
+	assert.True(t, d.Remote)
+
+	d = check(&model.JiraBranch{BranchName: "VM-2_x_dev", TargetBranch: "dev", State: model.JiraBranchPushed})
+	assert.Equal(t, model.JiraBranchClosed, d.State)
+	assert.True(t, d.NewMR)
+
+	d = check(&model.JiraBranch{BranchName: "VM-3_x_dev", TargetBranch: "dev", State: model.JiraBranchPushed})
+	assert.Equal(t, model.JiraBranchClosed, d.State)
+	assert.Nil(t, d.MR)
+
+	//MR已合并，源分支已删除
+	jb := &model.JiraBranch{BranchName: "VM-4_x_dev", TargetBranch: "dev", State: model.JiraBranchPushed, MergeRequests: []*model.MrInfo{{MrId: 2}}}
+	d = check(jb)
+	assert.Equal(t, model.JiraBranchMerged, d.State)
+	assert.False(t, d.NewMR)
+	d.Apply()
+	assert.True(t, jb.Merged)
+	assert.Equal(t, model.JiraBranchMerged, jb.State)
+	assert.Nil(t, check(jb))
+
+	assert.Nil(t, check(&model.JiraBranch{TargetBranch: "qa", State: model.JiraBranchPending}))
+}
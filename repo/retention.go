@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/goeoeo/gitx/model"
+)
+
+// DefaultMaxAge 临时分支默认的保留时长
+const DefaultMaxAge = 7 * 24 * time.Hour
+
+type (
+	// Retention 临时分支的保留策略，项目中未配置的项使用全局配置，全局未配置时使用默认值
+	Retention struct {
+		MaxAge    *time.Duration `yaml:"max_age"`    //最后一次push后保留的时长，如168h
+		RequireMr *bool          `yaml:"require_mr"` //只删除MR已合并或已关闭的分支
+		KeepLast  *int           `yaml:"keep_last"`  //每个目标分支保留最近push的N个临时分支
+	}
+
+	// RetentionPolicy 生效的保留策略
+	RetentionPolicy struct {
+		MaxAge    time.Duration
+		RequireMr bool
+		KeepLast  int
+	}
+)
+
+// RetentionPolicy 项目生效的保留策略
+func (c *Config) RetentionPolicy(project string) *RetentionPolicy {
+	p := &RetentionPolicy{MaxAge: DefaultMaxAge}
+	p.apply(c.Retention)
+	if r := c.Repo[project]; r != nil {
+		p.apply(r.Retention)
+	}
+	return p
+}
+
+func (p *RetentionPolicy) apply(r *Retention) {
+	if r == nil {
+		return
+	}
+	if r.MaxAge != nil {
+		p.MaxAge = *r.MaxAge
+	}
+	if r.RequireMr != nil {
+		p.RequireMr = *r.RequireMr
+	}
+	if r.KeepLast != nil {
+		p.KeepLast = *r.KeepLast
+	}
+}
+
+// Keep 判断临时分支是否需要保留，返回保留的原因，可以删除时返回空
+// rank为分支在同一目标分支中按push时间倒序的位置，mrState为最近一次MR的状态，没有MR时为空
+func (p *RetentionPolicy) Keep(jb *model.JiraBranch, rank int, mrState string, now time.Time) string {
+	if age := now.Sub(jb.UpdateTime); age < p.MaxAge {
+		return fmt.Sprintf("未超过保留时长%s", p.MaxAge)
+	}
+
+	if rank < p.KeepLast {
+		return fmt.Sprintf("目标分支最近的%d个分支", p.KeepLast)
+	}
+
+	if p.RequireMr {
+		switch mrState {
+		case MrStateMerged, MrStateClosed:
+		case "":
+			//没有MR时以记录的状态为准，如reconcile修正后的状态
+			if jb.State != model.JiraBranchMerged && jb.State != model.JiraBranchClosed {
+				return "MR未合并或关闭"
+			}
+		default:
+			return "MR未合并或关闭"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfig_RetentionPolicy(t *testing.T) {
+	var c *Config
+	assert.Nil(t, yaml.Unmarshal([]byte(`
+retention:
+  max_age: 72h
+  require_mr: true
+repo:
+  dev-tool:
+    retention:
+      keep_last: 2
+      require_mr: false
+  other: {}
+`), &c))
+
+	assert.Equal(t, &RetentionPolicy{MaxAge: 72 * time.Hour, RequireMr: false, KeepLast: 2}, c.RetentionPolicy("dev-tool"))
+	assert.Equal(t, &RetentionPolicy{MaxAge: 72 * time.Hour, RequireMr: true}, c.RetentionPolicy("other"))
+	assert.Equal(t, &RetentionPolicy{MaxAge: DefaultMaxAge}, (&Config{}).RetentionPolicy("dev-tool"))
+}
+
+func TestRetentionPolicy_Keep(t *testing.T) {
+	now := time.Now()
+	p := &RetentionPolicy{MaxAge: DefaultMaxAge, RequireMr: true, KeepLast: 1}
+	old := &model.JiraBranch{UpdateTime: now.Add(-8 * 24 * time.Hour), State: model.JiraBranchPushed}
+
+	assert.NotEmpty(t, p.Keep(&model.JiraBranch{UpdateTime: now.Add(-time.Hour)}, 3, MrStateMerged, now))
+	assert.NotEmpty(t, p.Keep(old, 0, MrStateMerged, now))
+	assert.NotEmpty(t, p.Keep(old, 1, MrStateOpened, now))
+	assert.NotEmpty(t, p.Keep(old, 1, "", now))
+	assert.Empty(t, p.Keep(old, 1, MrStateClosed, now))
+
+	old.State = model.JiraBranchMerged
+	assert.Empty(t, p.Keep(old, 1, "", now))
+}
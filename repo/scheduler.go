@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/goeoeo/gitx/model"
+)
+
+// Scheduler 并发执行多个项目、多个目标分支的push
+// 每个 项目/目标分支 在各自的工作树中执行，同时执行的数量不超过jobs
+type Scheduler struct {
+	jobs chan struct{}
+	jm   *model.JiraMgr //所有项目共用，保证jira记录不会互相覆盖
+}
+
+func NewScheduler(jobs int) (s *Scheduler, err error) {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	s = &Scheduler{jobs: make(chan struct{}, jobs)}
+	if s.jm, err = model.NewJiraMgr(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Push 并发执行各项目的push，结果按项目的顺序汇总，返回第一个出错项目的错误
+func (s *Scheduler) Push(patches []*RepoPatch) (results []*RepoPushResult, err error) {
+	var (
+		wg          sync.WaitGroup
+		projResults = make([][]*RepoPushResult, len(patches))
+		errs        = make([]error, len(patches))
+	)
+
+	for i, rp := range patches {
+		rp.jobs = s.jobs
+		rp.jm = s.jm
+
+		wg.Add(1)
+		go func(i int, rp *RepoPatch) {
+			defer wg.Done()
+			projResults[i], errs[i] = rp.Push()
+		}(i, rp)
+	}
+	wg.Wait()
+
+	for i := range patches {
+		results = append(results, projResults[i]...)
+		if err == nil && errs[i] != nil {
+			err = fmt.Errorf("%s: %w", patches[i].Repo.Name, errs[i])
+		}
+	}
+	return
+}
@@ -0,0 +1,34 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler_Push(t *testing.T) {
+	r1, _ := newConflictRepo(t)
+	r1.Name = "work1"
+	r2, dir2 := newConflictRepo(t)
+	cfg.Patch.ConflictPolicy = ConflictPolicyFailBranch
+	cfg.Patch.TgtBranchs = []string{"dev", "master"}
+
+	s, err := NewScheduler(3)
+	assert.Nil(t, err)
+
+	results, err := s.Push([]*RepoPatch{NewRepoPatch(r1, cfg), NewRepoPatch(r2, cfg)})
+	assert.Nil(t, err)
+	assert.Len(t, results, 4)
+	assert.Equal(t, "work1", results[0].Project)
+	assert.Equal(t, "work", results[2].Project)
+	for _, v := range results {
+		assert.Equal(t, v.TargetBranch == "dev", v.Failed)
+	}
+	gitRun(t, dir2, "rev-parse", "--verify", "origin/VM-1_x_master")
+
+	//两个项目的记录都已保存
+	jm, err := model.NewJiraMgr()
+	assert.Nil(t, err)
+	assert.Len(t, jm.JiraList, 2)
+}
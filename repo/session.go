@@ -0,0 +1,253 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/goeoeo/gitx/util"
+)
+
+// 目标分支的推送状态
+const (
+	SessionStatePending = "pending" //未开始
+	SessionStatePicking = "picking" //cherry-pick中
+	SessionStatePaused  = "paused"  //cherry-pick冲突，等待处理
+	SessionStateDone    = "done"    //已完成
+	SessionStateFailed  = "failed"  //cherry-pick冲突，按fail-branch策略放弃该分支
+)
+
+var (
+	ErrNoSession    = errors.New("没有未完成的push")
+	ErrSessionExist = errors.New("存在未完成的push，请使用 --continue 继续或 --abort 放弃")
+)
+
+type (
+	// PushSession 一次push的进度，保存在 ~/.patch/session/{project}.json
+	// 冲突或程序异常退出后可通过 push --continue 恢复
+	PushSession struct {
+		Project           string
+		JiraId            string
+		JiraDesc          string
+		CommitType        string
+		CommitMsg         string
+		DevBranch         string
+		IgnoreLocalCommit bool
+		Branches          []*BranchSession
+		CreateTime        time.Time
+		UpdateTime        time.Time
+		path              string
+		mu                sync.Mutex
+		snapshots         map[*BranchSession]json.RawMessage //各目标分支最近一次保存的内容
+	}
+
+	// BranchSession 单个目标分支的进度
+	BranchSession struct {
+		TgtBranch string
+		NewBranch string              //临时分支
+		Worktree  string              //临时分支的工作树目录
+		Commits   []*model.CommitInfo //待cherry-pick的commit，按cherry-pick的顺序排列
+		Index     int                 //下一个待处理的commit
+		State     string
+		Result    *RepoPushResult
+	}
+)
+
+func sessionPath(config *Config, project string) string {
+	return filepath.Join(config.HomeDir, "session", project+".json")
+}
+
+// NewPushSession 根据当前配置创建push进度
+func NewPushSession(config *Config, project string, ignoreLocalCommit bool) *PushSession {
+	s := &PushSession{
+		Project:           project,
+		JiraId:            config.Patch.JiraId,
+		JiraDesc:          config.Patch.JiraDesc,
+		CommitType:        config.Patch.CommitType,
+		CommitMsg:         config.Patch.CommitMsg,
+		DevBranch:         config.Patch.DevBranch,
+		IgnoreLocalCommit: ignoreLocalCommit,
+		CreateTime:        time.Now(),
+		path:              sessionPath(config, project),
+	}
+
+	for _, tgtBranch := range config.Patch.GetTgtBranchs() {
+		s.Branches = append(s.Branches, &BranchSession{
+			TgtBranch: tgtBranch,
+			State:     SessionStatePending,
+		})
+	}
+	return s
+}
+
+// LoadPushSession 载入项目未完成的push
+func LoadPushSession(config *Config, project string) (s *PushSession, err error) {
+	p := sessionPath(config, project)
+	if !util.FileExists(p) {
+		return nil, ErrNoSession
+	}
+
+	s = &PushSession{path: p}
+	if err = util.ReadJsonFile(p, s); err != nil {
+		return nil, fmt.Errorf("读取push进度失败:%s,%v", p, err)
+	}
+	return
+}
+
+// ListPushSessions 列出所有未完成的push
+func ListPushSessions(config *Config) (list []*PushSession, err error) {
+	var (
+		entries []os.DirEntry
+		s       *PushSession
+	)
+
+	dir := filepath.Join(config.HomeDir, "session")
+	if !util.FileExists(dir) {
+		return
+	}
+
+	if entries, err = os.ReadDir(dir); err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+
+		if s, err = LoadPushSession(config, strings.TrimSuffix(e.Name(), ".json")); err != nil {
+			return
+		}
+		list = append(list, s)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Project < list[j].Project
+	})
+	return
+}
+
+// Save 保存所有目标分支的进度，不能与SaveBranch并发调用
+func (s *PushSession) Save() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, bs := range s.Branches {
+		if err = s.snapshot(bs); err != nil {
+			return
+		}
+	}
+	return s.write()
+}
+
+// SaveBranch 保存单个目标分支的进度，并发push时由各分支自己调用
+// 其它分支使用最近一次保存的内容，避免读取正在被修改的进度
+func (s *PushSession) SaveBranch(bs *BranchSession) (err error) {
+	var (
+		b []byte
+	)
+
+	if b, err = json.Marshal(bs); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.snapshots == nil {
+		s.snapshots = make(map[*BranchSession]json.RawMessage)
+	}
+	s.snapshots[bs] = b
+
+	for _, v := range s.Branches {
+		if _, ok := s.snapshots[v]; !ok {
+			if err = s.snapshot(v); err != nil {
+				return
+			}
+		}
+	}
+	return s.write()
+}
+
+func (s *PushSession) snapshot(bs *BranchSession) (err error) {
+	var (
+		b []byte
+	)
+
+	if b, err = json.Marshal(bs); err != nil {
+		return
+	}
+
+	if s.snapshots == nil {
+		s.snapshots = make(map[*BranchSession]json.RawMessage)
+	}
+	s.snapshots[bs] = b
+	return
+}
+
+// pushSessionFile 保存到文件的内容
+type pushSessionFile PushSession
+
+func (s *PushSession) write() (err error) {
+	if err = os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return
+	}
+
+	s.UpdateTime = time.Now()
+	f := struct {
+		*pushSessionFile
+		Branches []json.RawMessage
+	}{pushSessionFile: (*pushSessionFile)(s)}
+	for _, bs := range s.Branches {
+		f.Branches = append(f.Branches, s.snapshots[bs])
+	}
+	return util.WriteJsonFile(s.path, f)
+}
+
+func (s *PushSession) Remove() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Done 所有目标分支均已处理完成
+func (s *PushSession) Done() bool {
+	for _, bs := range s.Branches {
+		if !bs.Finished() {
+			return false
+		}
+	}
+	return true
+}
+
+// Results 已处理分支的推送结果
+func (s *PushSession) Results() (results []*RepoPushResult) {
+	for _, bs := range s.Branches {
+		if bs.Finished() && bs.Result != nil && bs.Result.TargetBranch != "" {
+			results = append(results, bs.Result)
+		}
+	}
+	return
+}
+
+// Finished 分支已处理完成，成功或失败
+func (bs *BranchSession) Finished() bool {
+	return bs.State == SessionStateDone || bs.State == SessionStateFailed
+}
+
+// Rows 打印进度的表格行
+func (s *PushSession) Rows() (rows [][]string) {
+	for _, bs := range s.Branches {
+		rows = append(rows, []string{s.Project, s.JiraId, fmt.Sprintf("%s=>%s", s.DevBranch, bs.TgtBranch), bs.State,
+			fmt.Sprintf("%d/%d", bs.Index, len(bs.Commits)), bs.Worktree, s.UpdateTime.Format("2006-01-02 15:04:05")})
+	}
+	return
+}
@@ -0,0 +1,47 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushSession(t *testing.T) {
+	config := &Config{
+		HomeDir: t.TempDir(),
+		Patch: &Patch{
+			JiraId:      "VM-1",
+			DevBranch:   "feature",
+			TgtBranchs:  []string{"dev", "v6.0"},
+			BranchAlias: map[string]string{"v6.0": "QCE_V6.0"},
+		},
+	}
+
+	_, err := LoadPushSession(config, "gitx")
+	assert.ErrorIs(t, err, ErrNoSession)
+
+	s := NewPushSession(config, "gitx", false)
+	assert.Len(t, s.Branches, 2)
+	assert.Equal(t, "QCE_V6.0", s.Branches[1].TgtBranch)
+
+	s.Branches[0].State = SessionStateDone
+	s.Branches[0].Result = &RepoPushResult{TargetBranch: "dev"}
+	s.Branches[1].State = SessionStatePaused
+	s.Branches[1].Index = 1
+	assert.Nil(t, s.Save())
+
+	list, err := ListPushSessions(config)
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+
+	s, err = LoadPushSession(config, "gitx")
+	assert.Nil(t, err)
+	assert.Equal(t, "VM-1", s.JiraId)
+	assert.Equal(t, 1, s.Branches[1].Index)
+	assert.False(t, s.Done())
+	assert.Len(t, s.Results(), 1)
+
+	assert.Nil(t, s.Remove())
+	_, err = LoadPushSession(config, "gitx")
+	assert.ErrorIs(t, err, ErrNoSession)
+}
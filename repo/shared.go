@@ -0,0 +1,206 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/goeoeo/gitx/tracker"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	SharedRef       = "refs/gitx/jira"        //团队共享的jira记录，每个jira一个json文件
+	sharedRemoteRef = "refs/gitx/remote/jira" //拉取到本地的远程共享记录
+)
+
+// errSharedRejected 推送共享记录时远程已被其他人更新
+var errSharedRejected = errors.New("共享记录已被其他人更新")
+
+// SyncShared 与仓库中共享的jira记录双向同步：拉取远程的记录合并到本地，再将项目的记录推送到远程
+// 本地的 refs/gitx/jira 为上次同步的记录，与之对比识别本地或远程删除的记录
+// 推送时远程已被其他人更新则重新拉取合并
+func (g *GitRepo) SyncShared(jm *model.JiraMgr, project string) (err error) {
+	for i := 0; i < 3; i++ {
+		if err = g.syncShared(jm, project); !errors.Is(err, errSharedRejected) {
+			return
+		}
+		logrus.Debugf("共享记录已被其他人更新，重新同步:%s", g.Path)
+	}
+	return
+}
+
+func (g *GitRepo) syncShared(jm *model.JiraMgr, project string) (err error) {
+	var (
+		remote  string
+		records []*model.Jira
+		base    []*model.Jira
+		tree    string
+		ret     *CmdRet
+	)
+
+	if remote, err = g.fetchShared(); err != nil {
+		return
+	}
+
+	if remote != "" {
+		if records, err = g.readShared(remote); err != nil {
+			return
+		}
+		//上次同步的记录，用于识别删除和detach
+		if g.RefExists(SharedRef) {
+			if base, err = g.readShared(SharedRef); err != nil {
+				return
+			}
+		}
+		if jm.Merge(project, records, base) {
+			if err = jm.Save(); err != nil {
+				return
+			}
+		}
+	}
+
+	if tree, err = g.writeShared(jm.List(project)); err != nil {
+		return
+	}
+
+	//远程记录与本地相同，不需要推送
+	if remote != "" {
+		if ret, err = ExecCmd(g.Path, "git", "rev-parse", remote+"^{tree}"); err != nil {
+			return fmt.Errorf("读取共享记录失败:%s", ret.ErrStr)
+		}
+		if strings.TrimSpace(ret.Out) == tree {
+			if ret, err = ExecCmd(g.Path, "git", "update-ref", SharedRef, remote); err != nil {
+				return fmt.Errorf("更新%s失败:%s", SharedRef, ret.ErrStr)
+			}
+			return
+		}
+	}
+
+	args := []string{"commit-tree", tree, "-m", fmt.Sprintf("gitx: update %s", project)}
+	if remote != "" {
+		args = append(args, "-p", remote)
+	}
+	if ret, err = ExecCmd(g.Path, "git", args...); err != nil {
+		return fmt.Errorf("提交共享记录失败:%s", ret.ErrStr)
+	}
+	commit := strings.TrimSpace(ret.Out)
+
+	if ret, err = ExecCmdEnv(g.Path, nil, "", "git", "push", "origin", commit+":"+SharedRef); err != nil {
+		if strings.Contains(ret.ErrStr, "rejected") {
+			return errSharedRejected
+		}
+		return fmt.Errorf("推送共享记录失败:%s", ret.ErrStr)
+	}
+
+	//推送成功后才作为下次同步的基准，避免未推送的本地记录被当作远程已删除
+	if ret, err = ExecCmd(g.Path, "git", "update-ref", SharedRef, commit); err != nil {
+		return fmt.Errorf("更新%s失败:%s", SharedRef, ret.ErrStr)
+	}
+	return
+}
+
+// fetchShared 拉取远程的共享记录，远程没有共享记录时返回空
+func (g *GitRepo) fetchShared() (remote string, err error) {
+	ret, err := ExecCmdEnv(g.Path, nil, "", "git", "fetch", "origin", "+"+SharedRef+":"+sharedRemoteRef)
+	if err != nil {
+		if !strings.Contains(ret.ErrStr, "couldn't find remote ref") {
+			return "", fmt.Errorf("拉取共享记录失败:%s", ret.ErrStr)
+		}
+		_, _ = ExecCmd(g.Path, "git", "update-ref", "-d", sharedRemoteRef)
+		return "", nil
+	}
+
+	if ret, err = ExecCmd(g.Path, "git", "rev-parse", sharedRemoteRef); err != nil {
+		return "", fmt.Errorf("读取共享记录失败:%s", ret.ErrStr)
+	}
+	return strings.TrimSpace(ret.Out), nil
+}
+
+// readShared 读取commit中的共享记录
+func (g *GitRepo) readShared(commit string) (records []*model.Jira, err error) {
+	var (
+		ret   *CmdRet
+		blobs []string
+	)
+
+	if ret, err = ExecCmd(g.Path, "git", "ls-tree", commit); err != nil {
+		return nil, fmt.Errorf("读取共享记录失败:%s", ret.ErrStr)
+	}
+
+	//100644 blob <sha>\t<name>
+	for _, line := range strings.Split(strings.TrimSpace(ret.Out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[1] != "blob" || !strings.HasSuffix(fields[3], ".json") {
+			continue
+		}
+		blobs = append(blobs, fields[2])
+	}
+
+	if len(blobs) == 0 {
+		return
+	}
+
+	if ret, err = ExecCmdEnv(g.Path, nil, strings.Join(blobs, "\n")+"\n", "git", "cat-file", "--batch"); err != nil {
+		return nil, fmt.Errorf("读取共享记录失败:%s", ret.ErrStr)
+	}
+
+	//<sha> blob <size>\n<content>\n
+	out := ret.Out
+	for len(out) > 0 {
+		i := strings.IndexByte(out, '\n')
+		if i < 0 {
+			break
+		}
+		header := strings.Fields(out[:i])
+		if len(header) != 3 {
+			return nil, fmt.Errorf("无法解析共享记录:%s", out[:i])
+		}
+		size, _ := strconv.Atoi(header[2])
+		if i+1+size >= len(out) {
+			return nil, fmt.Errorf("无法解析共享记录:%s", out[:i])
+		}
+		content := out[i+1 : i+1+size]
+		out = out[i+1+size+1:]
+
+		var j *model.Jira
+		if err = json.Unmarshal([]byte(content), &j); err != nil {
+			return nil, fmt.Errorf("无法解析共享记录:%s,%v", header[0], err)
+		}
+		records = append(records, j)
+	}
+	return
+}
+
+// writeShared 将记录写入git对象库，返回tree
+func (g *GitRepo) writeShared(records []*model.Jira) (tree string, err error) {
+	var (
+		ret   *CmdRet
+		b     []byte
+		lines []string
+	)
+
+	for _, j := range records {
+		if b, err = json.MarshalIndent(j, "", "  "); err != nil {
+			return
+		}
+		if ret, err = ExecCmdEnv(g.Path, nil, string(b), "git", "hash-object", "-w", "--stdin"); err != nil {
+			return "", fmt.Errorf("写入共享记录失败:%s", ret.ErrStr)
+		}
+		lines = append(lines, fmt.Sprintf("100644 blob %s\t%s.json", strings.TrimSpace(ret.Out), tracker.BranchKey(j.JiraID)))
+	}
+	sort.Strings(lines)
+
+	stdin := ""
+	if len(lines) > 0 {
+		stdin = strings.Join(lines, "\n") + "\n"
+	}
+	if ret, err = ExecCmdEnv(g.Path, nil, stdin, "git", "mktree"); err != nil {
+		return "", fmt.Errorf("写入共享记录失败:%s", ret.ErrStr)
+	}
+	return strings.TrimSpace(ret.Out), nil
+}
@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goeoeo/gitx/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitRepo_SyncShared(t *testing.T) {
+	dir := newTestRepo(t)
+	dir2 := filepath.Join(filepath.Dir(dir), "work2")
+	gitRun(t, filepath.Dir(dir), "clone", filepath.Join(filepath.Dir(dir), "origin.git"), dir2)
+
+	newJm := func() *model.JiraMgr {
+		t.Setenv("HOME", t.TempDir())
+		jm, err := model.NewJiraMgr()
+		assert.Nil(t, err)
+		return jm
+	}
+
+	//远程没有共享记录时，推送本地记录
+	jm1 := newJm()
+	j := jm1.GetOrCreate("work", "VM-1", model.CommitTypeJira, "")
+	j.AddTargetBranch([]string{"dev", "qa"})
+	j.Append(&model.JiraBranch{BranchName: "VM-1_x_dev", DevBranch: "feature", TargetBranch: "dev"})
+	assert.Nil(t, jm1.Save())
+	g1 := NewGitRepo(dir, "")
+	assert.Nil(t, g1.SyncShared(jm1, "work"))
+	assert.NotEmpty(t, gitRun(t, filepath.Join(filepath.Dir(dir), "origin.git"), "rev-parse", SharedRef))
+
+	//同事的项目名可以不同
+	jm2 := newJm()
+	g2 := NewGitRepo(dir2, "")
+	assert.Nil(t, g2.SyncShared(jm2, "common"))
+	list := jm2.List("common")
+	assert.Len(t, list, 1)
+	assert.Equal(t, "VM-1", list[0].JiraID)
+	assert.Equal(t, []string{"dev", "qa"}, list[0].TargetBranch)
+	assert.Equal(t, model.JiraBranchPushed, list[0].BranchList[0].State)
+
+	//同事合入dev并推送qa
+	time.Sleep(10 * time.Millisecond)
+	list[0].BranchList[0].SetMerged()
+	list[0].BranchList[0].UpdateTime = time.Now()
+	list[0].Append(&model.JiraBranch{BranchName: "VM-1_x_qa", DevBranch: "feature", TargetBranch: "qa"})
+	assert.Nil(t, jm2.Save())
+	assert.Nil(t, g2.SyncShared(jm2, "common"))
+
+	assert.Nil(t, g1.SyncShared(jm1, "work"))
+	j = jm1.List("work")[0]
+	assert.Len(t, j.BranchList, 2)
+	assert.Equal(t, model.JiraBranchMerged, j.BranchList[0].State)
+	assert.Equal(t, "VM-1_x_qa", j.BranchList[1].BranchName)
+}
+
+func TestGitRepo_SyncSharedDelete(t *testing.T) {
+	dir := newTestRepo(t)
+	dir2 := filepath.Join(filepath.Dir(dir), "work2")
+	gitRun(t, filepath.Dir(dir), "clone", filepath.Join(filepath.Dir(dir), "origin.git"), dir2)
+
+	newJm := func() *model.JiraMgr {
+		t.Setenv("HOME", t.TempDir())
+		jm, err := model.NewJiraMgr()
+		assert.Nil(t, err)
+		return jm
+	}
+
+	jm1, g1 := newJm(), NewGitRepo(dir, "")
+	for _, id := range []string{"VM-1", "VM-2"} {
+		j := jm1.GetOrCreate("work", id, model.CommitTypeJira, "")
+		j.AddTargetBranch([]string{"dev", "qa"})
+		j.Append(&model.JiraBranch{BranchName: id + "_x_qa", DevBranch: "feature", TargetBranch: "qa"})
+	}
+	assert.Nil(t, jm1.Save())
+	assert.Nil(t, g1.SyncShared(jm1, "work"))
+
+	jm2, g2 := newJm(), NewGitRepo(dir2, "")
+	assert.Nil(t, g2.SyncShared(jm2, "work"))
+	assert.Len(t, jm2.List("work"), 2)
+
+	//同事删除VM-1、detach VM-2的qa
+	assert.Nil(t, jm2.DelJira("work", "VM-1"))
+	assert.Nil(t, jm2.Detach("work", "VM-2", "qa"))
+	assert.Nil(t, g2.SyncShared(jm2, "work"))
+	list := jm2.List("work")
+	assert.Len(t, list, 1)
+	assert.Equal(t, []string{"dev"}, list[0].TargetBranch)
+
+	//本地没有更新时同步删除，不会再加回
+	assert.Nil(t, g1.SyncShared(jm1, "work"))
+	list = jm1.List("work")
+	assert.Len(t, list, 1)
+	assert.Equal(t, "VM-2", list[0].JiraID)
+	assert.Equal(t, []string{"dev"}, list[0].TargetBranch)
+	assert.Len(t, list[0].BranchList, 0)
+
+	assert.Nil(t, g2.SyncShared(jm2, "work"))
+	assert.Len(t, jm2.List("work"), 1)
+}
@@ -0,0 +1,288 @@
+package repo
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goeoeo/gitx/util"
+	"github.com/sirupsen/logrus"
+)
+
+// 工作区操作的结果
+const (
+	WorkspaceCloned  = "cloned"  //已克隆
+	WorkspaceExists  = "exists"  //本地已存在
+	WorkspaceFetched = "fetched" //已拉取
+	WorkspaceFailed  = "failed"  //失败
+	WorkspaceMissing = "missing" //本地不存在
+)
+
+// DefaultWorkspaceRoot 未配置 workspace.root 时克隆仓库的根目录
+const DefaultWorkspaceRoot = "~/workspace"
+
+var workspaceMu sync.Mutex //克隆后记录仓库信息时加锁
+
+type (
+	// Workspace 工作区配置，仓库按 root/域名/组/项目 的目录结构克隆
+	Workspace struct {
+		Root string `yaml:"root"` //克隆仓库的根目录，默认 ~/workspace
+		Jobs int    `yaml:"jobs"` //并发执行的仓库数量，默认4
+		Ssh  bool   `yaml:"ssh"`  //使用ssh地址克隆，默认使用仓库的https地址
+	}
+
+	// WorkspaceStatus 工作区中单个仓库的状态
+	WorkspaceStatus struct {
+		Project string
+		Path    string
+		Url     string
+		Branch  string //当前分支
+		Dirty   int    //未提交的文件数
+		Ahead   int    //相对上游分支领先的commit数
+		Behind  int    //相对上游分支落后的commit数
+		Result  string
+		Error   string
+	}
+)
+
+// Workspace 生效的工作区配置
+func (c *Config) Workspace() *Workspace {
+	w := &Workspace{Root: DefaultWorkspaceRoot, Jobs: 4}
+	if c.WorkspaceConfig != nil {
+		w.Root = util.Default(c.WorkspaceConfig.Root, w.Root)
+		w.Ssh = c.WorkspaceConfig.Ssh
+		if c.WorkspaceConfig.Jobs > 0 {
+			w.Jobs = c.WorkspaceConfig.Jobs
+		}
+	}
+
+	if strings.HasPrefix(w.Root, "~") {
+		home, _ := os.UserHomeDir()
+		w.Root = filepath.Join(home, strings.TrimPrefix(w.Root, "~"))
+	}
+	return w
+}
+
+// RepoPath 仓库在工作区中的路径，如 https://gitlab.example.com/group/proj => root/gitlab.example.com/group/proj
+func (w *Workspace) RepoPath(repoUrl string) (string, error) {
+	u, err := url.Parse(repoUrl)
+	if err != nil || u.Path == "" {
+		return "", fmt.Errorf("无法解析仓库地址:%s", repoUrl)
+	}
+
+	p := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	return filepath.Join(w.Root, u.Host, filepath.FromSlash(p)), nil
+}
+
+// CloneUrl 克隆使用的地址，配置了ssh时 https://host/group/proj 转换为 git@host:group/proj.git
+func (w *Workspace) CloneUrl(repoUrl string) string {
+	u, err := url.Parse(repoUrl)
+	if !w.Ssh || err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return repoUrl
+	}
+	return fmt.Sprintf("git@%s:%s.git", u.Hostname(), strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git"))
+}
+
+// WorkspaceProjects 工作区中的项目，projects为空时返回所有项目，按名称排序
+func (c *Config) WorkspaceProjects(projects []string) (names []string, err error) {
+	if len(projects) == 0 {
+		for name := range c.Repo {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return
+	}
+
+	for _, p := range projects {
+		if p == "" {
+			continue
+		}
+		if _, ok := c.Repo[p]; !ok {
+			return nil, fmt.Errorf("找不到项目仓库信息:%s", p)
+		}
+		names = append(names, p)
+	}
+	return
+}
+
+// EnsureRepo 项目的本地仓库不存在时克隆到工作区，并记录到repo.json中
+func (c *Config) EnsureRepo(project string) (r *Repo, err error) {
+	var ok bool
+	if r, ok = c.Repo[project]; !ok {
+		return nil, fmt.Errorf("找不到项目仓库信息:%s", project)
+	}
+
+	if _, err = c.cloneRepo(project, r); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// LocalRepo 项目已存在的本地仓库，不会克隆，用于 push --dry-run、check 等不应有副作用的命令
+func (c *Config) LocalRepo(project string) (r *Repo, err error) {
+	var ok bool
+	if r, ok = c.Repo[project]; !ok {
+		return nil, fmt.Errorf("找不到项目仓库信息:%s", project)
+	}
+
+	if !isGitDir(r.Path) {
+		return nil, fmt.Errorf("项目 %s 本地仓库不存在，请先执行 gitx workspace clone -p %s", project, project)
+	}
+	return
+}
+
+// CloneRepos 并发克隆本地不存在的项目
+func (c *Config) CloneRepos(projects []string) []*WorkspaceStatus {
+	return c.eachRepo(projects, func(st *WorkspaceStatus, r *Repo) (err error) {
+		st.Result, err = c.cloneRepo(st.Project, r)
+		st.Path = r.Path
+		return
+	})
+}
+
+// SyncRepos 并发拉取本地已存在的项目
+func (c *Config) SyncRepos(projects []string) []*WorkspaceStatus {
+	return c.eachRepo(projects, func(st *WorkspaceStatus, r *Repo) error {
+		if !isGitDir(r.Path) {
+			st.Result = WorkspaceMissing
+			return nil
+		}
+
+		if ret, err := ExecCmd(r.Path, "git", "fetch", "--prune", "origin"); err != nil {
+			return fmt.Errorf("拉取失败:%s", strings.TrimSpace(ret.ErrStr))
+		}
+		st.Result = WorkspaceFetched
+		return nil
+	})
+}
+
+// ReposStatus 项目本地仓库的当前分支、未提交的文件数、相对上游分支的领先和落后
+func (c *Config) ReposStatus(projects []string) []*WorkspaceStatus {
+	return c.eachRepo(projects, func(st *WorkspaceStatus, r *Repo) error {
+		if !isGitDir(r.Path) {
+			st.Result = WorkspaceMissing
+			return nil
+		}
+		st.Result = WorkspaceExists
+
+		g := &GitRepo{Path: r.Path}
+		ret, err := ExecCmd(r.Path, "git", "rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil {
+			return fmt.Errorf("获取当前分支失败:%s", strings.TrimSpace(ret.ErrStr))
+		}
+		st.Branch = strings.TrimSpace(ret.Out)
+
+		if ret, err = ExecCmd(r.Path, "git", "status", "--porcelain"); err != nil {
+			return fmt.Errorf("获取工作区状态失败:%s", strings.TrimSpace(ret.ErrStr))
+		}
+		for _, line := range strings.Split(ret.Out, "\n") {
+			if strings.TrimSpace(line) != "" {
+				st.Dirty++
+			}
+		}
+
+		//没有上游分支时不统计
+		if g.RefExists("@{upstream}") {
+			st.Ahead, st.Behind = g.aheadBehind("HEAD", "@{upstream}")
+		}
+		return nil
+	})
+}
+
+// eachRepo 按 workspace.jobs 并发处理项目，结果按项目顺序返回
+func (c *Config) eachRepo(projects []string, fn func(st *WorkspaceStatus, r *Repo) error) (res []*WorkspaceStatus) {
+	var (
+		wg   sync.WaitGroup
+		jobs = make(chan struct{}, c.Workspace().Jobs)
+	)
+
+	for _, name := range projects {
+		r := c.Repo[name]
+		st := &WorkspaceStatus{Project: name, Path: r.Path, Url: r.Url}
+		res = append(res, st)
+
+		wg.Add(1)
+		go func(st *WorkspaceStatus, r *Repo) {
+			defer wg.Done()
+			jobs <- struct{}{}
+			defer func() { <-jobs }()
+
+			if err := fn(st, r); err != nil {
+				st.Result, st.Error = WorkspaceFailed, err.Error()
+				logrus.Debugf("%s: %v", st.Project, err)
+			}
+		}(st, r)
+	}
+	wg.Wait()
+	return
+}
+
+// cloneRepo 本地仓库不存在时按工作区的目录结构克隆
+func (c *Config) cloneRepo(project string, r *Repo) (result string, err error) {
+	if r.Path != "" && isGitDir(r.Path) {
+		return WorkspaceExists, nil
+	}
+
+	if r.Url == "" {
+		return "", fmt.Errorf("项目 %s 本地仓库不存在且缺少仓库地址", project)
+	}
+
+	w := c.Workspace()
+	path := r.Path
+	if path == "" {
+		if path, err = w.RepoPath(r.Url); err != nil {
+			return
+		}
+	}
+	if isGitDir(path) {
+		r.Path = path
+		return WorkspaceExists, c.recordRepo(project, r)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("创建目录失败:%v", err)
+	}
+
+	logrus.Infof("克隆 %s 到 %s", r.Url, path)
+	if ret, err := ExecCmd(filepath.Dir(path), "git", "clone", w.CloneUrl(r.Url), path); err != nil {
+		return "", fmt.Errorf("克隆失败:%s", strings.TrimSpace(ret.ErrStr))
+	}
+
+	r.Path = path
+	return WorkspaceCloned, c.recordRepo(project, r)
+}
+
+// recordRepo 将项目的本地路径记录到repo.json，其他命令通过 -p 指定项目时能找到仓库
+func (c *Config) recordRepo(project string, r *Repo) (err error) {
+	workspaceMu.Lock()
+	defer workspaceMu.Unlock()
+
+	if c.projectRepoUrl == nil {
+		c.projectRepoUrl = make(map[string]*Repo)
+		if err = c.RepoFile().Read(&c.projectRepoUrl); err != nil {
+			return
+		}
+	}
+
+	c.projectRepoUrl[project] = &Repo{Name: project, Path: r.Path, Url: r.Url, CreateMr: r.CreateMr}
+	return c.writeProjectRepoUrl()
+}
+
+func isGitDir(path string) bool {
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(path, ".git"))
+	return err == nil
+}
+
+// Row 表格中的一行
+func (st *WorkspaceStatus) Row() []string {
+	return []string{st.Project, util.Default(st.Branch, "-"), strconv.Itoa(st.Dirty), strconv.Itoa(st.Ahead),
+		strconv.Itoa(st.Behind), util.Default(st.Path, "-"), st.Result, st.Error}
+}
@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkspace_RepoPath(t *testing.T) {
+	w := &Workspace{Root: "/ws"}
+	p, err := w.RepoPath("https://gitlab.example.com/group/sub/proj.git")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.FromSlash("/ws/gitlab.example.com/group/sub/proj"), p)
+
+	_, err = w.RepoPath("https://gitlab.example.com")
+	assert.NotNil(t, err)
+
+	assert.Equal(t, "https://gitlab.example.com/group/proj", w.CloneUrl("https://gitlab.example.com/group/proj"))
+	w.Ssh = true
+	assert.Equal(t, "git@gitlab.example.com:group/proj.git", w.CloneUrl("https://gitlab.example.com/group/proj"))
+}
+
+func TestConfig_Workspace(t *testing.T) {
+	dir := newTestRepo(t)
+	root := filepath.Dir(dir)
+	assert.Nil(t, os.MkdirAll(cfg.HomeDir, 0755))
+	origin := "file://" + filepath.Join(root, "origin.git")
+
+	cfg.WorkspaceConfig = &Workspace{Root: filepath.Join(root, "ws"), Jobs: 2}
+	cfg.Repo["work"] = &Repo{Path: dir, Url: origin}
+	cfg.Repo["proj"] = &Repo{Url: origin}
+
+	names, err := cfg.WorkspaceProjects(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"proj", "work"}, names)
+	_, err = cfg.WorkspaceProjects([]string{"none"})
+	assert.NotNil(t, err)
+
+	//未克隆时不会克隆
+	_, err = cfg.LocalRepo("proj")
+	assert.NotNil(t, err)
+	r, err := cfg.LocalRepo("work")
+	assert.Nil(t, err)
+	assert.Equal(t, dir, r.Path)
+
+	res := cfg.CloneRepos(names)
+	assert.Equal(t, WorkspaceCloned, res[0].Result, res[0].Error)
+	assert.Equal(t, WorkspaceExists, res[1].Result)
+	path := filepath.Join(root, "ws", filepath.Join(root, "origin"))
+	assert.Equal(t, path, cfg.Repo["proj"].Path)
+
+	//克隆后记录到repo.json
+	var recorded map[string]*Repo
+	assert.Nil(t, cfg.RepoFile().Read(&recorded))
+	assert.Equal(t, path, recorded["proj"].Path)
+
+	r, err = cfg.EnsureRepo("proj")
+	assert.Nil(t, err)
+	assert.Equal(t, path, r.Path)
+
+	gitCommit(t, dir, "b.txt", "b\n", "b")
+	gitRun(t, dir, "push", "origin", "master")
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "c.txt"), []byte("c\n"), 0644))
+
+	res = cfg.SyncRepos(names)
+	assert.Equal(t, WorkspaceFetched, res[0].Result, res[0].Error)
+
+	res = cfg.ReposStatus(names)
+	assert.Equal(t, "master", res[0].Branch)
+	assert.Equal(t, 1, res[0].Behind)
+	assert.Equal(t, 0, res[0].Dirty)
+	assert.Equal(t, 1, res[1].Dirty)
+	assert.Equal(t, 0, res[1].Ahead)
+}
@@ -0,0 +1,158 @@
+package tracker
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// GitLabIssueConfig GitLab issues 的配置
+type GitLabIssueConfig struct {
+	BaseUrl        string `yaml:"base_url"`
+	Token          string `yaml:"token"`            //为空时使用 gitLab_configs 中相同地址的token
+	Project        string `yaml:"project"`          //#123 形式的key所属的项目，如 group/proj，为空时使用当前项目
+	CloseOnResolve bool   `yaml:"close_on_resolve"` //所有目标分支合入后关闭issue
+	FailLabel      string `yaml:"fail_label"`       //MR合并失败时添加的标签
+}
+
+// #123 或 group/proj#123
+var gitLabKeyRe = regexp.MustCompile(`(?:^|[^\w/.-])((?:[\w.-]+/)+[\w.-]+)?#(\d+)\b`)
+
+// GitLabIssues 以GitLab issues作为任务跟踪系统
+type GitLabIssues struct {
+	config *GitLabIssueConfig
+}
+
+func NewGitLabIssues(c *GitLabIssueConfig) *GitLabIssues {
+	return &GitLabIssues{config: c}
+}
+
+func (g *GitLabIssues) Name() string {
+	return "gitlab"
+}
+
+func (g *GitLabIssues) FindKey(msg string) string {
+	m := gitLabKeyRe.FindStringSubmatch(msg)
+	if m == nil {
+		return ""
+	}
+	return m[1] + "#" + m[2]
+}
+
+func (g *GitLabIssues) Match(key string) bool {
+	return key != "" && g.FindKey(key) == key
+}
+
+func (g *GitLabIssues) Enabled() bool {
+	return g.config.BaseUrl != "" && g.config.Token != ""
+}
+
+func (g *GitLabIssues) GetIssue(key string) (issue *Issue, err error) {
+	var (
+		client *gitlab.Client
+		res    *gitlab.Issue
+	)
+
+	pid, iid, err := g.parseKey(key)
+	if err != nil {
+		return
+	}
+	if client, err = g.client(); err != nil {
+		return
+	}
+
+	if res, _, err = client.Issues.GetIssue(pid, iid); err != nil {
+		return
+	}
+
+	issue = &Issue{
+		Key:     key,
+		Summary: res.Title,
+		Status:  res.State,
+		WebUrl:  res.WebURL,
+		Labels:  res.Labels,
+	}
+	if res.Milestone != nil {
+		issue.FixVersions = []string{res.Milestone.Title}
+	}
+	return
+}
+
+func (g *GitLabIssues) AddComment(key, body string) (err error) {
+	var (
+		client *gitlab.Client
+	)
+
+	pid, iid, err := g.parseKey(key)
+	if err != nil {
+		return
+	}
+	if client, err = g.client(); err != nil {
+		return
+	}
+
+	_, _, err = client.Notes.CreateIssueNote(pid, iid, &gitlab.CreateIssueNoteOptions{Body: gitlab.Ptr(body)})
+	return
+}
+
+func (g *GitLabIssues) Resolve(key string, tgtBranches []string) error {
+	if !g.config.CloseOnResolve {
+		return nil
+	}
+	return g.update(key, &gitlab.UpdateIssueOptions{StateEvent: gitlab.Ptr("close")})
+}
+
+func (g *GitLabIssues) MarkFailed(key string) error {
+	if g.config.FailLabel == "" {
+		return nil
+	}
+	return g.update(key, &gitlab.UpdateIssueOptions{AddLabels: &gitlab.LabelOptions{g.config.FailLabel}})
+}
+
+func (g *GitLabIssues) update(key string, opt *gitlab.UpdateIssueOptions) (err error) {
+	var (
+		client *gitlab.Client
+	)
+
+	pid, iid, err := g.parseKey(key)
+	if err != nil {
+		return
+	}
+	if client, err = g.client(); err != nil {
+		return
+	}
+
+	_, _, err = client.Issues.UpdateIssue(pid, iid, opt)
+	return
+}
+
+func (g *GitLabIssues) client() (*gitlab.Client, error) {
+	if !g.Enabled() {
+		return nil, ErrNotConfigured
+	}
+	return gitlab.NewClient(g.config.Token, gitlab.WithBaseURL(g.config.BaseUrl))
+}
+
+// parseKey 解析出issue所属的项目和编号
+func (g *GitLabIssues) parseKey(key string) (pid string, iid int, err error) {
+	i := strings.LastIndex(key, "#")
+	if i < 0 {
+		return "", 0, fmt.Errorf("不是GitLab issue:%s", key)
+	}
+
+	if iid, err = strconv.Atoi(key[i+1:]); err != nil {
+		return "", 0, fmt.Errorf("不是GitLab issue:%s", key)
+	}
+
+	pid = key[:i]
+	if pid == "" {
+		pid = g.config.Project
+	}
+	if pid == "" {
+		return "", 0, fmt.Errorf("未配置issue所属的项目:%s", key)
+	}
+	return
+}
@@ -0,0 +1,227 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// JiraConfig Jira服务的配置
+type JiraConfig struct {
+	BaseUrl  string          `yaml:"base_url"`
+	Token    string          `yaml:"token"` //Jira Server/Data Center 的 Personal Access Token，或 Jira Cloud 的 API Token
+	User     string          `yaml:"user"`  //Jira Cloud 的登录邮箱，配置后使用 Basic 认证
+	Workflow *WorkflowConfig `yaml:"workflow"`
+}
+
+// Issue Jira任务
+type Issue struct {
+	Key             string
+	Summary         string
+	Status          string
+	WebUrl          string
+	FixVersions     []string
+	AffectsVersions []string
+	Labels          []string
+}
+
+var jiraKeyRe = regexp.MustCompile(`\b[A-Z]+-\d+\b`)
+
+// Jira 以Jira作为任务跟踪系统
+type Jira struct {
+	projects []string //Jira项目前缀，优先匹配
+	client   *JiraClient
+}
+
+// NewJira 未配置Jira时只用于提取任务key
+func NewJira(c *JiraConfig, projects []string) *Jira {
+	return &Jira{
+		projects: projects,
+		client:   NewJiraClient(c),
+	}
+}
+
+func (j *Jira) Name() string {
+	return "jira"
+}
+
+func (j *Jira) FindKey(msg string) string {
+	//配置了jira项目前缀
+	for _, p := range j.projects {
+		re := regexp.MustCompile(fmt.Sprintf(`%s-\w+`, p))
+		if match := re.FindString(msg); match != "" {
+			return match
+		}
+	}
+
+	return jiraKeyRe.FindString(msg)
+}
+
+func (j *Jira) Match(key string) bool {
+	return key != "" && j.FindKey(key) == key
+}
+
+func (j *Jira) Enabled() bool {
+	return j.client != nil
+}
+
+func (j *Jira) GetIssue(key string) (*Issue, error) {
+	if j.client == nil {
+		return nil, ErrNotConfigured
+	}
+	return j.client.GetIssue(key)
+}
+
+func (j *Jira) AddComment(key, body string) error {
+	if j.client == nil {
+		return ErrNotConfigured
+	}
+	return j.client.AddComment(key, body)
+}
+
+func (j *Jira) Resolve(key string, tgtBranches []string) error {
+	if j.client == nil {
+		return ErrNotConfigured
+	}
+	return j.client.Resolve(key, tgtBranches)
+}
+
+func (j *Jira) MarkFailed(key string) error {
+	if j.client == nil {
+		return ErrNotConfigured
+	}
+	return j.client.MarkFailed(key)
+}
+
+// JiraClient Jira REST API 客户端
+type JiraClient struct {
+	config *JiraConfig
+	client *http.Client
+}
+
+// NewJiraClient 未配置Jira时返回nil
+func NewJiraClient(c *JiraConfig) *JiraClient {
+	if c == nil || c.BaseUrl == "" {
+		return nil
+	}
+
+	return &JiraClient{
+		config: c,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type version struct {
+	Name string `json:"name"`
+}
+
+// GetIssue 获取任务的标题、状态、版本和标签
+func (c *JiraClient) GetIssue(key string) (issue *Issue, err error) {
+	var resp struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Summary string `json:"summary"`
+			Status  struct {
+				Name string `json:"name"`
+			} `json:"status"`
+			FixVersions []version `json:"fixVersions"`
+			Versions    []version `json:"versions"`
+			Labels      []string  `json:"labels"`
+		} `json:"fields"`
+	}
+
+	path := fmt.Sprintf("/rest/api/2/issue/%s?fields=summary,status,fixVersions,versions,labels", url.PathEscape(key))
+	if err = c.do(http.MethodGet, path, nil, &resp); err != nil {
+		return
+	}
+
+	issue = &Issue{
+		Key:     resp.Key,
+		Summary: resp.Fields.Summary,
+		Status:  resp.Fields.Status.Name,
+		WebUrl:  c.IssueUrl(resp.Key),
+		Labels:  resp.Fields.Labels,
+	}
+	for _, v := range resp.Fields.FixVersions {
+		issue.FixVersions = append(issue.FixVersions, v.Name)
+	}
+	for _, v := range resp.Fields.Versions {
+		issue.AffectsVersions = append(issue.AffectsVersions, v.Name)
+	}
+	return
+}
+
+// AddComment 在任务下添加评论
+func (c *JiraClient) AddComment(key, body string) error {
+	path := fmt.Sprintf("/rest/api/2/issue/%s/comment", url.PathEscape(key))
+	return c.do(http.MethodPost, path, map[string]string{"body": body}, nil)
+}
+
+// IssueUrl 任务的浏览地址
+func (c *JiraClient) IssueUrl(key string) string {
+	return strings.TrimRight(c.config.BaseUrl, "/") + "/browse/" + key
+}
+
+func (c *JiraClient) do(method, path string, in, out any) (err error) {
+	var (
+		body io.Reader
+		req  *http.Request
+		resp *http.Response
+		b    []byte
+	)
+
+	if in != nil {
+		if b, err = json.Marshal(in); err != nil {
+			return
+		}
+		body = bytes.NewReader(b)
+	}
+
+	if req, err = http.NewRequest(method, strings.TrimRight(c.config.BaseUrl, "/")+path, body); err != nil {
+		return
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.config.User != "" {
+		req.SetBasicAuth(c.config.User, c.config.Token)
+	} else if c.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+
+	if resp, err = c.client.Do(req); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if b, err = io.ReadAll(resp.Body); err != nil {
+		return
+	}
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("jira %s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+
+	if out == nil || len(b) == 0 {
+		return
+	}
+	return json.Unmarshal(b, out)
+}
+
+var slugRe = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// Slug 将任务标题转换为可以用在分支名中的描述，只保留字母和数字
+func Slug(summary string) string {
+	s := strings.Trim(slugRe.ReplaceAllString(summary, "_"), "_")
+	if len(s) > 30 {
+		s = strings.TrimRight(s[:30], "_")
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package tracker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJiraClient(t *testing.T) {
+	var comment map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rest/api/2/issue/VM-1", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "summary,status,fixVersions,versions,labels", r.URL.Query().Get("fields"))
+		_, _ = w.Write([]byte(`{"key":"VM-1","fields":{"summary":"Tpsc migrate","status":{"name":"In Progress"},` +
+			`"fixVersions":[{"name":"6.1.3"}],"versions":[{"name":"6.0.9"}],"labels":["hotfix"]}}`))
+	})
+	mux.HandleFunc("/rest/api/2/issue/VM-1/comment", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&comment))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	assert.Nil(t, NewJiraClient(nil))
+	c := NewJiraClient(&JiraConfig{BaseUrl: server.URL, Token: "token"})
+
+	issue, err := c.GetIssue("VM-1")
+	assert.Nil(t, err)
+	assert.Equal(t, &Issue{Key: "VM-1", Summary: "Tpsc migrate", Status: "In Progress", WebUrl: server.URL + "/browse/VM-1",
+		FixVersions: []string{"6.1.3"}, AffectsVersions: []string{"6.0.9"}, Labels: []string{"hotfix"}}, issue)
+
+	assert.Nil(t, c.AddComment("VM-1", "dev: https://mr"))
+	assert.Equal(t, "dev: https://mr", comment["body"])
+
+	_, err = c.GetIssue("VM-2")
+	assert.NotNil(t, err)
+}
+
+func TestJiraClient_BasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "dev@example.com", user)
+		assert.Equal(t, "token", pass)
+		_, _ = w.Write([]byte(`{"key":"VM-1","fields":{"summary":"x","status":{"name":"Done"}}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewJiraClient(&JiraConfig{BaseUrl: server.URL, Token: "token", User: "dev@example.com"}).GetIssue("VM-1")
+	assert.Nil(t, err)
+}
+
+func TestSlug(t *testing.T) {
+	assert.Equal(t, "Tpsc_Migrate", Slug("Tpsc Migrate"))
+	assert.Equal(t, "bug_fix_login_bug", Slug("【bug】fix login bug!"))
+	assert.Equal(t, "", Slug("修复登录问题"))
+	assert.Equal(t, "a_very_long_summary_that_shoul", Slug("a very long summary that should be cut"))
+}
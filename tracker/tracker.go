@@ -0,0 +1,55 @@
+package tracker
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrNotConfigured 未配置任务跟踪系统的API
+var ErrNotConfigured = errors.New("未配置任务跟踪系统")
+
+// Tracker 任务跟踪系统，如 Jira、GitLab issues
+type Tracker interface {
+	// Name 跟踪系统名称，记录在 LinkInfo 中
+	Name() string
+	// FindKey 从commit message中提取任务key，没有时返回空字符串
+	FindKey(msg string) string
+	// Match key是否属于该跟踪系统
+	Match(key string) bool
+	// Enabled 是否配置了API，未配置时只用于提取任务key
+	Enabled() bool
+	GetIssue(key string) (*Issue, error)
+	AddComment(key, body string) error
+	// Resolve 所有目标分支合入后按配置流转任务
+	Resolve(key string, tgtBranches []string) error
+	// MarkFailed MR合并失败时按配置标记任务
+	MarkFailed(key string) error
+}
+
+// FindKey 依次使用各跟踪系统从commit message中提取任务key
+func FindKey(trackers []Tracker, msg string) string {
+	for _, t := range trackers {
+		if key := t.FindKey(msg); key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// Find 查找key所属的跟踪系统
+func Find(trackers []Tracker, key string) Tracker {
+	for _, t := range trackers {
+		if t.Match(key) {
+			return t
+		}
+	}
+	return nil
+}
+
+var branchKeyRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// BranchKey 将任务key转换为可以用在分支名中的形式，如 group/proj#123 => group-proj-123
+func BranchKey(key string) string {
+	return strings.Trim(branchKeyRe.ReplaceAllString(key, "-"), "-")
+}
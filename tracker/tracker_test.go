@@ -0,0 +1,81 @@
+package tracker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindKey(t *testing.T) {
+	trackers := []Tracker{NewJira(nil, []string{"vm"}), NewGitLabIssues(&GitLabIssueConfig{})}
+
+	assert.Equal(t, "VM-12", FindKey(trackers, "VM-12 fix login"))
+	assert.Equal(t, "vm-a1", FindKey(trackers, "fix vm-a1 login"))
+	assert.Equal(t, "#123", FindKey(trackers, "fix login #123"))
+	assert.Equal(t, "group/proj#123", FindKey(trackers, "fix login (group/proj#123)"))
+	assert.Equal(t, "group/sub/proj#7", FindKey(trackers, "group/sub/proj#7: fix"))
+	assert.Equal(t, "", FindKey(trackers, "fix login"))
+	assert.Equal(t, "", FindKey(trackers, "see http://a.com/b#12x"))
+
+	assert.Equal(t, "gitlab", Find(trackers, "group/proj#123").Name())
+	assert.Equal(t, "gitlab", Find(trackers, "#123").Name())
+	assert.Equal(t, "jira", Find(trackers, "VM-1").Name())
+	assert.Nil(t, Find(trackers, "fix VM-1"))
+
+	assert.False(t, trackers[0].Enabled())
+	_, err := trackers[0].GetIssue("VM-1")
+	assert.Equal(t, ErrNotConfigured, err)
+}
+
+func TestBranchKey(t *testing.T) {
+	assert.Equal(t, "VM-12", BranchKey("VM-12"))
+	assert.Equal(t, "123", BranchKey("#123"))
+	assert.Equal(t, "group-proj-123", BranchKey("group/proj#123"))
+}
+
+func TestGitLabIssues(t *testing.T) {
+	var (
+		note   map[string]string
+		update map[string]any
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/group/proj/issues/12", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token", r.Header.Get("PRIVATE-TOKEN"))
+		if r.Method == http.MethodPut {
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&update))
+		}
+		_, _ = w.Write([]byte(`{"id":1012,"iid":12,"title":"Fix login","state":"opened","web_url":"https://gitlab/group/proj/-/issues/12",` +
+			`"labels":["backend"],"milestone":{"title":"v6.1"}}`))
+	})
+	mux.HandleFunc("/api/v4/projects/group/proj/issues/12/notes", func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&note))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":1}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	g := NewGitLabIssues(&GitLabIssueConfig{BaseUrl: server.URL, Token: "token", Project: "group/proj", CloseOnResolve: true, FailLabel: "backport-failed"})
+	assert.True(t, g.Enabled())
+
+	issue, err := g.GetIssue("#12")
+	assert.Nil(t, err)
+	assert.Equal(t, &Issue{Key: "#12", Summary: "Fix login", Status: "opened", WebUrl: "https://gitlab/group/proj/-/issues/12",
+		Labels: []string{"backend"}, FixVersions: []string{"v6.1"}}, issue)
+
+	assert.Nil(t, g.AddComment("group/proj#12", "dev: https://mr"))
+	assert.Equal(t, "dev: https://mr", note["body"])
+
+	assert.Nil(t, g.Resolve("#12", []string{"dev"}))
+	assert.Equal(t, "close", update["state_event"])
+
+	assert.Nil(t, g.MarkFailed("#12"))
+	assert.Equal(t, "backport-failed", update["add_labels"])
+
+	_, err = NewGitLabIssues(&GitLabIssueConfig{BaseUrl: server.URL, Token: "token"}).GetIssue("#12")
+	assert.NotNil(t, err)
+}
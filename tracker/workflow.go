@@ -0,0 +1,104 @@
+package tracker
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WorkflowConfig 任务流转的配置
+type WorkflowConfig struct {
+	ResolveTransition string            `yaml:"resolve_transition"` //所有目标分支合入后执行的流转，如 Resolved
+	FixVersions       map[string]string `yaml:"fix_versions"`       //目标分支对应的修复版本，流转前添加到任务的 fixVersion
+	FailLabel         string            `yaml:"fail_label"`         //MR合并失败时添加的标签
+}
+
+// Resolve 所有目标分支合入后，设置修复版本并流转任务，未配置时不处理
+func (c *JiraClient) Resolve(key string, tgtBranches []string) (err error) {
+	var (
+		versions []string
+	)
+
+	wf := c.config.Workflow
+	if wf == nil {
+		return
+	}
+
+	for _, branch := range tgtBranches {
+		if v, ok := wf.FixVersions[branch]; ok && v != "" {
+			versions = append(versions, v)
+		}
+	}
+
+	if err = c.AddFixVersions(key, versions); err != nil {
+		return
+	}
+
+	if wf.ResolveTransition == "" {
+		return
+	}
+	return c.Transition(key, wf.ResolveTransition)
+}
+
+// MarkFailed MR合并失败时给任务添加标签，未配置时不处理
+func (c *JiraClient) MarkFailed(key string) error {
+	if c.config.Workflow == nil || c.config.Workflow.FailLabel == "" {
+		return nil
+	}
+	return c.AddLabel(key, c.config.Workflow.FailLabel)
+}
+
+// Transition 按名称执行任务的流转，名称可以是流转名或流转后的状态名
+func (c *JiraClient) Transition(key, name string) (err error) {
+	var (
+		resp struct {
+			Transitions []struct {
+				Id   string `json:"id"`
+				Name string `json:"name"`
+				To   struct {
+					Name string `json:"name"`
+				} `json:"to"`
+			} `json:"transitions"`
+		}
+		names []string
+	)
+
+	path := fmt.Sprintf("/rest/api/2/issue/%s/transitions", url.PathEscape(key))
+	if err = c.do(http.MethodGet, path, nil, &resp); err != nil {
+		return
+	}
+
+	for _, t := range resp.Transitions {
+		if strings.EqualFold(t.Name, name) || strings.EqualFold(t.To.Name, name) {
+			body := map[string]any{"transition": map[string]string{"id": t.Id}}
+			return c.do(http.MethodPost, path, body, nil)
+		}
+		names = append(names, t.Name)
+	}
+
+	return fmt.Errorf("任务%s当前不能流转到%s，可用的流转:%s", key, name, strings.Join(names, ","))
+}
+
+// AddFixVersions 给任务添加修复版本
+func (c *JiraClient) AddFixVersions(key string, versions []string) error {
+	var ops []map[string]any
+	for _, v := range versions {
+		ops = append(ops, map[string]any{"add": map[string]string{"name": v}})
+	}
+	return c.update(key, "fixVersions", ops)
+}
+
+// AddLabel 给任务添加标签
+func (c *JiraClient) AddLabel(key, label string) error {
+	return c.update(key, "labels", []map[string]any{{"add": label}})
+}
+
+func (c *JiraClient) update(key, field string, ops []map[string]any) error {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	path := fmt.Sprintf("/rest/api/2/issue/%s", url.PathEscape(key))
+	return c.do(http.MethodPut, path, map[string]any{"update": map[string]any{field: ops}}, nil)
+}
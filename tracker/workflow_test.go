@@ -0,0 +1,67 @@
+package tracker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJiraClient_Workflow(t *testing.T) {
+	var (
+		updates    []map[string]any
+		transition map[string]map[string]string
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rest/api/2/issue/VM-1", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		var body map[string]any
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		updates = append(updates, body["update"].(map[string]any))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/rest/api/2/issue/VM-1/transitions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"transitions":[{"id":"11","name":"Start","to":{"name":"In Progress"}},{"id":"31","name":"Resolve Issue","to":{"name":"Resolved"}}]}`))
+			return
+		}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&transition))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewJiraClient(&JiraConfig{BaseUrl: server.URL, Workflow: &WorkflowConfig{
+		ResolveTransition: "resolved",
+		FixVersions:       map[string]string{"v6.1": "6.1.3", "v6.2": "6.2.0"},
+		FailLabel:         "backport-failed",
+	}})
+
+	assert.Nil(t, c.Resolve("VM-1", []string{"dev", "v6.1", "v6.2"}))
+	assert.Equal(t, "31", transition["transition"]["id"])
+	assert.Len(t, updates, 1)
+	assert.Equal(t, []any{
+		map[string]any{"add": map[string]any{"name": "6.1.3"}},
+		map[string]any{"add": map[string]any{"name": "6.2.0"}},
+	}, updates[0]["fixVersions"])
+
+	assert.Nil(t, c.MarkFailed("VM-1"))
+	assert.Len(t, updates, 2)
+	assert.Equal(t, []any{map[string]any{"add": "backport-failed"}}, updates[1]["labels"])
+
+	assert.NotNil(t, c.Transition("VM-1", "Closed"))
+}
+
+func TestJiraClient_WorkflowNotConfigured(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+	}))
+	defer server.Close()
+
+	c := NewJiraClient(&JiraConfig{BaseUrl: server.URL})
+	assert.Nil(t, c.Resolve("VM-1", []string{"dev"}))
+	assert.Nil(t, c.MarkFailed("VM-1"))
+}
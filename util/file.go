@@ -43,7 +43,7 @@ func FindOriginURL(pwd string) (string, error) {
 		if inOriginSection && strings.HasPrefix(line, "url") {
 			parts := strings.SplitN(line, "=", 2)
 			if len(parts) == 2 {
-				url := convertGitToHTTP(strings.TrimSpace(parts[1]))
+				url := ConvertGitToHTTP(strings.TrimSpace(parts[1]))
 				url = strings.TrimSuffix(url, ".git")
 				return url, nil
 			}
@@ -53,8 +53,8 @@ func FindOriginURL(pwd string) (string, error) {
 	return "", fmt.Errorf("查找远程URL失败")
 }
 
-// 将 Git 协议 URL 转换为 HTTP(S)
-func convertGitToHTTP(url string) string {
+// ConvertGitToHTTP 将 Git 协议 URL 转换为 HTTP(S)
+func ConvertGitToHTTP(url string) string {
 	// 匹配 SSH 格式 (git@host:path)
 	sshPattern := regexp.MustCompile(`^git@([\w.-]+):(.+\.git)$`)
 	if matches := sshPattern.FindStringSubmatch(url); matches != nil {
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"github.com/olekukonko/tablewriter"
@@ -22,3 +23,11 @@ func PrintJson(obj any) {
 	c, _ := json.MarshalIndent(obj, "", "	")
 	fmt.Println(string(c))
 }
+
+func PrintCsv(rows [][]string, header []string) {
+	w := csv.NewWriter(os.Stdout)
+	if len(header) > 0 {
+		w.Write(header)
+	}
+	w.WriteAll(rows)
+}
@@ -10,3 +10,7 @@ func TestPrintTable(t *testing.T) {
 
 	PrintTable(rows, nil)
 }
+
+func TestPrintCsv(t *testing.T) {
+	PrintCsv([][]string{{"1", "a,b", "3"}}, []string{"x", "y", "z"})
+}